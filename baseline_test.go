@@ -0,0 +1,159 @@
+package bcast
+
+import (
+	"container/heap"
+	"sync"
+	"testing"
+)
+
+// The delivery paths replaced by the ring buffer are kept here to
+// compare BenchmarkBroadcast with them. The fan-out dispatcher starts
+// a goroutine per member for every message as the first versions of
+// the package did, the queue dispatcher pushes every message to the
+// queues of the members ordered by the clocks.
+
+type baselineMessage struct {
+	payload int
+	clock   int
+}
+
+// clockQueue is a min-heap of the messages by their clocks.
+type clockQueue []baselineMessage
+
+func (q clockQueue) Len() int            { return len(q) }
+func (q clockQueue) Less(i, j int) bool  { return q[i].clock < q[j].clock }
+func (q clockQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *clockQueue) Push(x interface{}) { *q = append(*q, x.(baselineMessage)) }
+
+func (q *clockQueue) Pop() interface{} {
+	old := *q
+	message := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return message
+}
+
+type baselineMember struct {
+	read  chan int
+	send  chan baselineMessage // used by the fan-out only
+	lock  sync.Mutex           // guards the queue of the queue dispatcher
+	queue clockQueue
+	clock int
+	wake  chan struct{}
+	quit  chan struct{}
+}
+
+// listenFanout reorders the messages coming from the goroutines of the
+// fan-out dispatcher by their clocks.
+func (m *baselineMember) listenFanout() {
+	for {
+		select {
+		case message := <-m.send:
+			heap.Push(&m.queue, message)
+			for m.queue.Len() > 0 && m.queue[0].clock == m.clock {
+				select {
+				case m.read <- heap.Pop(&m.queue).(baselineMessage).payload:
+				case <-m.quit:
+					return
+				}
+				m.clock++
+			}
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// listenQueue takes the next message from the queue filled by the
+// queue dispatcher.
+func (m *baselineMember) listenQueue() {
+	for {
+		m.lock.Lock()
+		if m.queue.Len() > 0 && m.queue[0].clock == m.clock {
+			message := heap.Pop(&m.queue).(baselineMessage)
+			m.clock++
+			m.lock.Unlock()
+			select {
+			case m.read <- message.payload:
+			case <-m.quit:
+				return
+			}
+			continue
+		}
+		m.lock.Unlock()
+		select {
+		case <-m.wake:
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+func benchmarkBaseline(b *testing.B, count int, fanout bool) {
+	in := make(chan int)
+	quit := make(chan struct{})
+	done := make(chan bool)
+	members := make([]*baselineMember, count)
+	for i := range members {
+		m := &baselineMember{
+			read: make(chan int),
+			send: make(chan baselineMessage),
+			wake: make(chan struct{}, 1),
+			quit: quit,
+		}
+		members[i] = m
+		if fanout {
+			go m.listenFanout()
+		} else {
+			go m.listenQueue()
+		}
+		go func() {
+			for n := 0; n < b.N; n++ {
+				<-m.read
+			}
+			done <- true
+		}()
+	}
+	go func() {
+		for clock := 0; ; clock++ {
+			var message baselineMessage
+			select {
+			case val := <-in:
+				message = baselineMessage{payload: val, clock: clock}
+			case <-quit:
+				return
+			}
+			for _, m := range members {
+				if fanout {
+					go func(m *baselineMember) {
+						select {
+						case m.send <- message:
+						case <-quit:
+						}
+					}(m)
+					continue
+				}
+				m.lock.Lock()
+				heap.Push(&m.queue, message)
+				m.lock.Unlock()
+				signal(m.wake)
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		in <- n
+	}
+	for range members {
+		<-done
+	}
+	b.StopTimer()
+	close(quit)
+}
+
+func BenchmarkBaselineFanout10(b *testing.B)  { benchmarkBaseline(b, 10, true) }
+func BenchmarkBaselineFanout100(b *testing.B) { benchmarkBaseline(b, 100, true) }
+func BenchmarkBaselineFanout500(b *testing.B) { benchmarkBaseline(b, 500, true) }
+func BenchmarkBaselineQueues10(b *testing.B)  { benchmarkBaseline(b, 10, false) }
+func BenchmarkBaselineQueues100(b *testing.B) { benchmarkBaseline(b, 100, false) }
+func BenchmarkBaselineQueues500(b *testing.B) { benchmarkBaseline(b, 500, false) }
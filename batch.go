@@ -0,0 +1,149 @@
+package bcast
+
+import (
+	"iter"
+	"sync"
+	"time"
+)
+
+// batch collects the values for RecvBatch. The listen goroutine
+// appends the values it would otherwise send to the Read channel one
+// by one.
+type batch[T any] struct {
+	lock   sync.Mutex
+	values []T
+	max    int  // zero for no limit
+	wait   bool // keep open until the first value arrives
+	closed bool
+	done   chan struct{}
+}
+
+func (b *batch[T]) add(val T) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return false
+	}
+	b.values = append(b.values, val)
+	if b.max > 0 && len(b.values) >= b.max {
+		b.close()
+	}
+	return true
+}
+
+// ready reports whether the batch may be returned when nothing else
+// is queued for the member.
+func (b *batch[T]) ready() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.closed || len(b.values) > 0 || !b.wait
+}
+
+func (b *batch[T]) finish() {
+	b.lock.Lock()
+	b.close()
+	b.lock.Unlock()
+}
+
+func (b *batch[T]) close() {
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
+
+func (b *batch[T]) take() []T {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.values
+}
+
+// RecvBatch waits up to wait for the first value and returns it
+// together with the values already queued for the member, up to max
+// values in the order Read would deliver them: the group order, or
+// the priority order once priorities are in use. Zero max means no
+// limit, zero wait returns only the values already queued without
+// waiting. The values are passed by the listen goroutine of the member
+// at once instead of one channel operation per value. It returns nil
+// if nothing arrived in time, the member has left the group or it
+// receives envelopes instead of the values.
+func (m *TypedMember[T]) RecvBatch(max int, wait time.Duration) []T {
+	if m.envelopes != nil {
+		return nil
+	}
+	b := &batch[T]{max: max, wait: wait > 0, done: make(chan struct{})}
+	var expired <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case m.batches <- b:
+	case <-expired:
+		return nil
+	case <-m.done:
+		return nil
+	}
+	select {
+	case <-b.done:
+	case <-expired:
+		b.finish()
+	}
+	return b.take()
+}
+
+// Pending returns the iterator over the values already queued for the
+// member in the order of RecvBatch. The values are taken from the
+// member when the loop starts, so breaking out of the loop drops the
+// rest of them.
+func (m *TypedMember[T]) Pending() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, val := range m.RecvBatch(0, 0) {
+			if !yield(val) {
+				return
+			}
+		}
+	}
+}
+
+// sendValue passes the value to the open batch or to the Read channel
+// the same way as sendTo does, taking the batches requested meanwhile.
+func (m *TypedMember[T]) sendValue(val T) bool {
+	if m.dropping {
+		return false
+	}
+	quit := m.quit
+	var expired <-chan time.Time
+	for {
+		if m.batch != nil {
+			if m.batch.add(val) {
+				return true
+			}
+			m.batch = nil
+		}
+		select {
+		case m.read <- val:
+			return true
+		case m.batch = <-m.batches:
+		case <-quit:
+			// The fields set by stop are read after quit.
+			if m.dropping = m.drainUntil < 0; m.dropping || m.collect {
+				return false
+			}
+			quit, expired = nil, m.expired
+		case <-expired:
+			m.collect = true
+			return false
+		}
+	}
+}
+
+// flushBatch returns the open batch to the reader if it may be
+// returned.
+func (m *TypedMember[T]) flushBatch(force bool) {
+	if m.batch != nil && (force || m.batch.ready()) {
+		m.batch.finish()
+		m.batch = nil
+	}
+}
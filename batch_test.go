@@ -0,0 +1,103 @@
+package bcast
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Create new broadcast group.
+// Send several messages while the member does not read.
+// Check that the batches hold the queued messages in order up to the limit.
+func TestRecvBatch(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.Join()
+	go group.Broadcast(0)
+	sendHeld(t, group, func() {
+		for i := 1; i <= 4; i++ {
+			group.Send(i)
+		}
+	})
+	if batch := member.RecvBatch(3, 0); !reflect.DeepEqual(batch, []int{-1, 1, 2}) {
+		t.Fatalf("unexpected batch %v", batch)
+	}
+	if batch := member.RecvBatch(0, 0); !reflect.DeepEqual(batch, []int{3, 4}) {
+		t.Fatalf("unexpected batch %v", batch)
+	}
+	if batch := member.RecvBatch(0, 0); len(batch) != 0 {
+		t.Fatalf("unexpected batch %v", batch)
+	}
+	if batch := member.RecvBatch(0, 10*time.Millisecond); batch != nil {
+		t.Fatalf("unexpected batch %v", batch)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		group.Send(5)
+	}()
+	if batch := member.RecvBatch(0, time.Second); !reflect.DeepEqual(batch, []int{5}) {
+		t.Fatalf("unexpected batch %v", batch)
+	}
+	group.Send(6)
+	expectOrder(t, member, 6)
+	group.Close()
+	if batch := member.RecvBatch(0, time.Second); batch != nil {
+		t.Fatalf("unexpected batch %v after close", batch)
+	}
+}
+
+// Create new broadcast group.
+// Send several messages while the member does not read.
+// Check that the iterator yields all of them in order.
+func TestPending(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.Join()
+	go group.Broadcast(0)
+	sendHeld(t, group, func() {
+		for i := 1; i <= 3; i++ {
+			group.Send(i)
+		}
+	})
+	var values []int
+	for val := range member.Pending() {
+		values = append(values, val)
+	}
+	if !reflect.DeepEqual(values, []int{-1, 1, 2, 3}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join a member receiving envelopes and a member blocked on a request.
+// Check that the batches of them return at once instead of hanging.
+func TestRecvBatchBlocked(t *testing.T) {
+	group := NewGroupOf[int]()
+	go group.Broadcast(0)
+	envelopes := group.JoinWithOptions(WithEnvelopes())
+	server := group.JoinWithOptions(WithRequests())
+	client := group.Join()
+	group.Send(1)
+	<-server.Read
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Request(ctx, 2)
+	time.Sleep(10 * time.Millisecond)
+	group.Send(3)
+	done := make(chan bool)
+	go func() {
+		if batch := envelopes.RecvBatch(0, 0); batch != nil {
+			t.Errorf("unexpected batch %v", batch)
+		}
+		if batch := server.RecvBatch(0, 0); len(batch) != 0 {
+			t.Errorf("unexpected batch %v", batch)
+		}
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("batch hangs")
+	}
+	group.Close()
+}
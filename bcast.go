@@ -10,210 +10,967 @@ package bcast
 */
 
 import (
-	"container/heap"
+	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	// ErrGroupClosed is returned when sending to a group that has
+	// been closed.
+	ErrGroupClosed = errors.New("Group is closed")
+	// ErrMemberClosed is returned when receiving from a member that
+	// has already left its group.
+	ErrMemberClosed = errors.New("Member is closed")
+	// ErrMemberNotFound is returned when removing a member that is
+	// not in the group.
+	ErrMemberNotFound = errors.New("Could not find provided member for removal")
+)
+
+// MemberID identifies a member within its group. IDs are assigned in
+// the order the members join, starting from 1.
+type MemberID uint64
+
 // Message is an internal structure to pack messages together with
 // info about sender.
-type Message struct {
-	sender  *Member
-	payload interface{}
-	clock   int
+type Message[T any] struct {
+	sender   *TypedMember[T]
+	payload  T
+	clock    int64
+	topic    string
+	call     *call[T]                   // set for requests
+	where    func(*TypedMember[T]) bool // selects the receivers of targeted messages
+	sent     time.Time
+	headers  map[string]string
+	trace    context.Context // carries the span of the message
+	from     MemberID        // sender of the message restored from the log
+	priority int
+	deadline time.Time // zero if the message never expires
 }
 
-// Member represents member of a Broadcast group.
-type Member struct {
-	group        *Group
-	Read         chan interface{}
-	clock        int
-	messageQueue PriorityQueue
-	send         chan Message
-	close        chan bool
+// TypedMember represents member of a broadcast group of values of
+// type T.
+type TypedMember[T any] struct {
+	group        *TypedGroup[T]
+	id           MemberID
+	Read         chan T
+	read         chan T
+	requests     chan *Request[T]
+	envelopes    chan Envelope[T]
+	opts         memberOptions
+	filter       atomic.Pointer[func(T) bool]
+	topics       atomic.Pointer[[]string] // replaced under lock
+	clock        atomic.Int64             // cursor in the ring, changed under lock
+	drops        atomic.Int64
+	expiredCount atomic.Int64
+	conflated    atomic.Int64
+	received     atomic.Int64
+	delivered    atomic.Int64
+	latency      atomic.Int64 // total nanoseconds from send to delivery
+	maxLatency   atomic.Int64
+	lock         sync.Mutex // guards the fields down to evicted
+	skipped      []int64    // clocks of the dropped messages ahead of the cursor
+	evicted      bool
+	wake         chan struct{} // signals the listen goroutine about new messages
+	room         chan struct{} // signals the blocked dispatcher about free space
+	quit         chan struct{} // closed to stop the listen goroutine
+	done         chan struct{} // closed when the listen goroutine exits
+	replay       []*Message[T] // retained messages to deliver before the ring
+	drainUntil   int64         // clock to deliver up to after quit, -1 to drop
+	expired      <-chan time.Time
+	collect      bool
+	dropping     bool
+	undelivered  []T
+	lanes        laneQueue[T]            // messages taken from the ring by priority, under lock
+	queued       atomic.Int64            // length of lanes
+	bypassed     int                     // deliveries ahead of an older message in a row
+	keys         map[string]*laneItem[T] // waiting messages by the conflation keys, under lock
+	conflate     Keyer[T]
+	batches      chan *batch[T] // batches requested by RecvBatch
+	batch        *batch[T]      // batch being filled by the listen goroutine
+}
+
+// TypedGroup provides a mechanism for the broadcast of values of type
+// T to a collection of channels. Values are delivered to the members
+// as is, without type assertions on the receiving side.
+type TypedGroup[T any] struct {
+	in           chan Message[T]
+	quit         chan struct{}
+	closeOnce    sync.Once
+	closed       bool
+	stopOnce     sync.Once
+	stopped      bool // members are stopped, nothing is published anymore
+	running      int  // number of active dispatchers
+	opts         groupOptions
+	members      []*TypedMember[T]
+	index        map[MemberID]int  // positions of the members
+	snapshot     []*TypedMember[T] // members the dispatcher works on, under dispatchLock
+	pending      []*Message[T]     // stamped messages to publish in order, under clockLock
+	published    []*Message[T]     // spare buffer for pending, under dispatchLock
+	lastID       MemberID
+	leaving      []*TypedMember[T] // members draining after leave
+	clock        int64
+	unstamped    int        // messages accepted by a dispatcher but not stamped yet
+	stamped      *sync.Cond // signalled when unstamped drops to zero
+	ring         atomic.Pointer[ring[T]]
+	head         atomic.Int64  // messages below this clock are readable from the ring
+	retained     *retention[T] // under clockLock
+	log          *Log
+	durables     map[string]*TypedMember[T] // under memberLock
+	events       chan MembershipEvent
+	eventPump    *pump[MembershipEvent]  // feeds events, under memberLock
+	eventConvert func(MembershipEvent) T // under clockLock
+	noticed      []MembershipEvent       // changes to pass to the observers, under memberLock
+	notifyLock   sync.Mutex              // serializes notifying the observers
+	joined       int64                   // membership counters, under memberLock
+	left         int64
+	evicted      int64
+	accepted     atomic.Int64
+	delivered    atomic.Int64
+	dropped      atomic.Int64
+	expired      atomic.Int64
+	conflated    atomic.Int64
+	maxReorder   atomic.Int64 // changed under dispatchLock
+	prioritized  atomic.Bool  // a message with a priority was sent
+	memberLock   sync.Mutex
+	clockLock    sync.Mutex
+	dispatchLock sync.Mutex // serializes publishing to the ring
 }
 
 // Group provides a mechanism for the broadcast of messages to a
-// collection of channels.
-type Group struct {
-	in         chan Message
-	close      chan bool
-	members    []*Member
-	clock      int
-	memberLock sync.Mutex
-	clockLock  sync.Mutex
+// collection of channels. It is the untyped group kept for the code
+// written before typed groups appeared.
+type Group = TypedGroup[any]
+
+// Member represents member of a Broadcast group.
+type Member = TypedMember[any]
+
+// NewGroup creates a new broadcast group for untyped values. It is
+// the same as NewGroupOf[any]().
+func NewGroup(opts ...GroupOption) *Group {
+	return NewGroupOf[any](opts...)
 }
 
-// NewGroup creates a new broadcast group.
-func NewGroup() *Group {
-	in := make(chan Message)
-	close := make(chan bool)
-	return &Group{in: in, close: close, clock: 0}
+// NewGroupOf creates a new broadcast group for values of type T.
+func NewGroupOf[T any](opts ...GroupOption) *TypedGroup[T] {
+	g := &TypedGroup[T]{
+		in:    make(chan Message[T]),
+		quit:  make(chan struct{}),
+		index: make(map[MemberID]int),
+		clock: 0,
+	}
+	g.stamped = sync.NewCond(&g.clockLock)
+	g.opts.ringSize = DefaultRingSize
+	for _, opt := range opts {
+		opt(&g.opts)
+	}
+	g.ring.Store(newRing[T](g.opts.ringSize))
+	g.retained = newRetention[T](g.opts.retainLast)
+	return g
 }
 
 // MemberCount returns the number of members in the Broadcast Group.
-func (g *Group) MemberCount() int {
+func (g *TypedGroup[T]) MemberCount() int {
 	return len(g.Members())
 }
 
-// Members returns a slice of Members that are currently in the Group.
-func (g *Group) Members() []*Member {
+// Members returns a copy of the slice of Members that are currently
+// in the Group. The order of the members is not defined.
+func (g *TypedGroup[T]) Members() []*TypedMember[T] {
 	g.memberLock.Lock()
-	res := g.members[:]
+	res := make([]*TypedMember[T], len(g.members))
+	copy(res, g.members)
 	g.memberLock.Unlock()
 	return res
 }
 
+// Member returns the member of the Group with the provided ID.
+func (g *TypedGroup[T]) Member(id MemberID) (*TypedMember[T], bool) {
+	g.memberLock.Lock()
+	defer g.memberLock.Unlock()
+	if index, ok := g.index[id]; ok {
+		return g.members[index], true
+	}
+	return nil, false
+}
+
 // Join returns a new member object and handles the creation of its
 // output channel.
-func (g *Group) Join() *Member {
-	memberChannel := make(chan interface{})
+func (g *TypedGroup[T]) Join() *TypedMember[T] {
+	memberChannel := make(chan T)
 	return g.Add(memberChannel)
 }
 
-// Leave removes the provided member from the group and closes him
-func (g *Group) Leave(leaving *Member) error {
+// Leave removes the provided member from the group and closes him.
+// Read channel of the member is closed when Leave returns. Messages
+// not yet delivered to the member are dropped, use LeaveGraceful to
+// deliver them.
+func (g *TypedGroup[T]) Leave(leaving *TypedMember[T]) error {
+	_, err := g.leave(leaving, false, nil, false)
+	return err
+}
+
+// LeaveGraceful removes the provided member from the group like
+// Leave but first delivers to its Read channel every message the
+// group has sent before the call. Messages which were not read during
+// timeout are returned undelivered, so they may be redelivered
+// elsewhere. Zero timeout waits until all the messages are read.
+func (g *TypedGroup[T]) LeaveGraceful(leaving *TypedMember[T], timeout time.Duration) ([]T, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	return g.leave(leaving, true, expired, false)
+}
+
+func (g *TypedGroup[T]) leave(leaving *TypedMember[T], drain bool, expired <-chan time.Time, collect bool) ([]T, error) {
+	g.clockLock.Lock()
+	settle := g.eventConvert != nil
+	g.clockLock.Unlock()
+	drainUntil := int64(-1)
+	if drain {
+		drainUntil = g.sentClock()
+	} else if settle && !leaving.Evicted() {
+		// The messages sent before the leave are stamped ahead of
+		// the Left event. The evicting dispatcher may not wait for
+		// the others.
+		g.sentClock()
+	}
 	g.memberLock.Lock()
-	memberIndex := -1
-	for index, member := range g.members {
-		if member == leaving {
-			memberIndex = index
-			break
-		}
+	memberIndex, ok := g.index[leaving.id]
+	if !ok || g.members[memberIndex] != leaving {
+		g.memberLock.Unlock()
+		return nil, ErrMemberNotFound
+	}
+	// Move the last member to the place of the leaving one.
+	last := len(g.members) - 1
+	g.members[memberIndex] = g.members[last]
+	g.index[g.members[memberIndex].id] = memberIndex
+	g.members[last] = nil
+	g.members = g.members[:last]
+	delete(g.index, leaving.id)
+	if d := leaving.opts.durable; d != nil && g.durables[d.name] == leaving {
+		delete(g.durables, d.name)
+	}
+	kind := Left
+	if leaving.Evicted() {
+		kind = Evicted
+	}
+	g.clockLock.Lock()
+	g.emit(kind, leaving.id, g.clock)
+	g.clockLock.Unlock()
+	if drain {
+		// The draining member still holds the dispatcher from
+		// overwriting the messages it has not read yet.
+		g.leaving = append(g.leaving, leaving)
 	}
-	if memberIndex == -1 {
+	g.memberLock.Unlock()
+	g.publishPending()
+	g.notify()
+	leaving.stop(drainUntil, expired, collect)
+	<-leaving.done
+	if drain {
+		g.memberLock.Lock()
+		for index, member := range g.leaving {
+			if member == leaving {
+				g.leaving = append(g.leaving[:index:index], g.leaving[index+1:]...)
+				break
+			}
+		}
 		g.memberLock.Unlock()
-		return errors.New("Could not find provided member for removal")
 	}
-	g.members = append(g.members[:memberIndex], g.members[memberIndex+1:]...)
-	leaving.close <- true // TODO: need to handle the case where there
-	close(leaving.Read)
+	return leaving.undelivered, nil
+}
 
-	// is still stuff in this Members priorityQueue
-	g.memberLock.Unlock()
-	return nil
+// JoinWithOptions returns a new member object configured with the
+// provided options and handles the creation of its output channel.
+func (g *TypedGroup[T]) JoinWithOptions(opts ...MemberOption) *TypedMember[T] {
+	memberChannel := make(chan T)
+	return g.add(memberChannel, nil, nil, opts)
 }
 
-// Add adds a member to the group for the provided interface channel.
-func (g *Group) Add(memberChannel chan interface{}) *Member {
+// JoinFiltered returns a new member object which receives only the
+// messages accepted by the filter.
+func (g *TypedGroup[T]) JoinFiltered(filter func(payload T) bool) *TypedMember[T] {
+	memberChannel := make(chan T)
+	return g.add(memberChannel, filter, nil, nil)
+}
+
+// Add adds a member to the group for the provided channel.
+func (g *TypedGroup[T]) Add(memberChannel chan T) *TypedMember[T] {
+	return g.add(memberChannel, nil, nil, nil)
+}
+
+func (g *TypedGroup[T]) add(memberChannel chan T, filter func(T) bool, conflate Keyer[T], opts []MemberOption) *TypedMember[T] {
+	g.clockLock.Lock()
+	settle := g.retained != nil || g.eventConvert != nil
+	g.clockLock.Unlock()
+	if settle {
+		// Let the messages sent before the join get retained and
+		// stamped ahead of the Joined event.
+		g.sentClock()
+	}
+	member := &TypedMember[T]{
+		group: g,
+		Read:  memberChannel,
+		read:  memberChannel,
+		wake:  make(chan struct{}, 1),
+		room:  make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+
+		batches:  make(chan *batch[T]),
+		conflate: conflate,
+	}
+	for _, opt := range opts {
+		opt(&member.opts)
+	}
 	g.memberLock.Lock()
 	g.clockLock.Lock()
-	member := &Member{
-		group:        g,
-		Read:         memberChannel,
-		clock:        g.clock,
-		messageQueue: PriorityQueue{},
-		send:         make(chan Message),
-		close:        make(chan bool),
-	}
-	go member.listen()
-	g.members = append(g.members, member)
+	g.lastID++
+	member.id = g.lastID
+	member.clock.Store(g.clock)
+	member.SetFilter(filter)
+	if member.opts.requests {
+		member.requests = make(chan *Request[T])
+	}
+	if member.opts.envelopes {
+		member.envelopes = make(chan Envelope[T])
+	}
+	if len(member.opts.topics) > 0 {
+		member.Subscribe(member.opts.topics...)
+	}
+	// The logged messages are read without the locks held, they
+	// replace the retained ones.
+	replayLog := g.log != nil && member.opts.replay && !g.closed
+	if g.retained != nil && !replayLog {
+		member.replay = g.retained.messages()
+	}
+	if !replayLog {
+		go member.listen()
+	}
+	joined := g.clock
+	if g.closed {
+		member.stop(-1, nil, false)
+	} else {
+		g.index[member.id] = len(g.members)
+		g.members = append(g.members, member)
+		g.emit(Joined, member.id, joined)
+	}
 	g.clockLock.Unlock()
 	g.memberLock.Unlock()
+	g.publishPending()
+	g.notify()
+	if replayLog {
+		var durable string
+		if d := member.opts.durable; d != nil {
+			durable = d.name
+		}
+		member.replay = g.replayLog(member.opts.replayFrom, joined, durable)
+		go member.listen()
+	}
 	return member
 }
 
-// Close terminates the group immediately.
-func (g *Group) Close() {
-	g.close <- true
+// Close terminates the group immediately. The dispatcher stops, all
+// members leave the group and their Read channels get closed after
+// the undelivered messages are drained or dropped according to the
+// group ClosePolicy. Sends made after Close return ErrGroupClosed.
+// Close may be called many times and from any goroutine, it doesn't
+// wait for the members to drain.
+func (g *TypedGroup[T]) Close() {
+	g.closeOnce.Do(func() {
+		g.memberLock.Lock()
+		g.clockLock.Lock()
+		g.closed = true
+		close(g.quit)
+		idle := g.running == 0
+		g.emit(GroupClosed, 0, g.clock)
+		g.clockLock.Unlock()
+		g.memberLock.Unlock()
+		// A running dispatcher stops the members itself when it
+		// is done with the messages it has already accepted.
+		if idle {
+			g.stopMembers()
+		}
+	})
+}
+
+// evict removes the member which has overflowed its buffer.
+func (g *TypedGroup[T]) evict(member *TypedMember[T]) {
+	member.lock.Lock()
+	member.evicted = true
+	member.lock.Unlock()
+	g.leave(member, false, nil, false)
+}
+
+// stopMembers removes all the members from the closed group and
+// makes them drain or drop undelivered messages.
+func (g *TypedGroup[T]) stopMembers() {
+	g.stopOnce.Do(func() {
+		g.dispatchLock.Lock()
+		defer g.dispatchLock.Unlock()
+		g.clockLock.Lock()
+		g.stopped = true
+		g.clockLock.Unlock()
+		// Nothing is stamped anymore, the messages stamped before
+		// are published to be drained.
+		g.publish()
+		g.memberLock.Lock()
+		g.clockLock.Lock()
+		if g.log != nil {
+			g.log.close()
+		}
+		drainUntil := int64(-1)
+		if g.opts.closePolicy == DrainOnClose {
+			drainUntil = g.clock
+		}
+		members := g.members
+		g.members = nil
+		g.index = nil
+		g.clockLock.Unlock()
+		g.memberLock.Unlock()
+		for _, member := range members {
+			member.stop(drainUntil, nil, false)
+		}
+	})
 }
 
 // Broadcast messages received from one group member to others.
 // If incoming messages not arrived during `timeout` then function returns.
-func (g *Group) Broadcast(timeout time.Duration) {
-	var timeoutChannel <-chan time.Time
-	if timeout != 0 {
-		timeoutChannel = time.After(timeout)
+func (g *TypedGroup[T]) Broadcast(timeout time.Duration) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
+	g.BroadcastContext(ctx)
+}
+
+// BroadcastContext broadcasts messages the same way as Broadcast
+// until ctx is done or the group is closed. It returns ctx.Err() when
+// stopped by the context and nil when stopped by Close.
+func (g *TypedGroup[T]) BroadcastContext(ctx context.Context) error {
+	g.clockLock.Lock()
+	g.running++
+	g.clockLock.Unlock()
+	defer func() {
+		g.clockLock.Lock()
+		g.running--
+		stop := g.running == 0 && g.closed
+		g.clockLock.Unlock()
+		if stop {
+			g.stopMembers()
+		}
+	}()
 	for {
 		select {
 		case received := <-g.in:
-			g.memberLock.Lock()
-			g.clockLock.Lock()
-			members := g.members[:]
-			received.clock = g.clock
-			g.clock++
-			g.clockLock.Unlock()
-			g.memberLock.Unlock()
-			for _, member := range members {
-				// This is done in a goroutine because if it
-				// weren't it would be a blocking call
-				go func(member *Member, received Message) {
-					member.send <- received
-				}(member, received)
-			}
-		case <-timeoutChannel:
-			if timeout > 0 {
-				return
-			}
-		case <-g.close:
+			g.dispatch(&received)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-g.quit:
+			return nil
+		}
+	}
+}
+
+// dispatch stamps the message with the group clock and publishes it
+// to the ring applying the overflow policies of the members.
+func (g *TypedGroup[T]) dispatch(message *Message[T]) {
+	g.dispatchLock.Lock()
+	g.clockLock.Lock()
+	// Accepted by a dispatcher started after the group was closed
+	// the message is not stamped, nobody is listening.
+	stopped := g.stopped
+	if !stopped {
+		if g.opts.tracer != nil {
+			// The span is set before the message is retained,
+			// the joining members read it without the locks.
+			span := g.traceDispatch(message, g.clock)
+			defer span.End()
+		}
+		g.stamp(message)
+	}
+	g.unstamped--
+	if g.unstamped == 0 {
+		g.stamped.Broadcast()
+	}
+	g.clockLock.Unlock()
+	g.publish()
+	g.dispatchLock.Unlock()
+	g.publishPending()
+}
+
+// stamp gives the message the next clock of the group and queues it
+// for publishing. It is called under the clock lock, the messages are
+// published in the order they are stamped.
+func (g *TypedGroup[T]) stamp(message *Message[T]) {
+	message.clock = g.clock
+	g.clock++
+	if message.call == nil && message.where == nil && g.retained != nil {
+		g.retained.add(message)
+	}
+	g.pending = append(g.pending, message)
+}
+
+// publish publishes the stamped messages to the ring in order. It is
+// called under the dispatch lock.
+func (g *TypedGroup[T]) publish() {
+	for {
+		g.memberLock.Lock()
+		g.clockLock.Lock()
+		pending := g.pending
+		g.pending = g.published[:0]
+		// Members are removed in place, so the dispatcher works
+		// on a copy.
+		g.snapshot = append(append(g.snapshot[:0], g.members...), g.leaving...)
+		g.clockLock.Unlock()
+		g.memberLock.Unlock()
+		if len(pending) == 0 {
+			g.published = pending
 			return
 		}
+		for i, message := range pending {
+			g.put(message, g.snapshot)
+			pending[i] = nil
+		}
+		g.published = pending
+	}
+}
+
+// publishPending publishes the messages stamped outside of the
+// dispatcher, such as the membership events, unless the dispatch lock
+// is taken. Whoever takes the lock publishes them before releasing
+// it and checks for the new ones after, so none is left behind.
+func (g *TypedGroup[T]) publishPending() {
+	for {
+		g.clockLock.Lock()
+		pending := len(g.pending)
+		g.clockLock.Unlock()
+		if pending == 0 || !g.dispatchLock.TryLock() {
+			return
+		}
+		g.publish()
+		g.dispatchLock.Unlock()
+	}
+}
+
+// put publishes the message to the ring applying the overflow
+// policies of the members. The message is written to the log first.
+func (g *TypedGroup[T]) put(message *Message[T], members []*TypedMember[T]) {
+	if g.log != nil {
+		g.logMessage(message, members)
+	}
+	r := g.ring.Load()
+	for _, member := range members {
+		if !member.makeRoom(message) {
+			g.evict(member)
+			continue
+		}
+		// Never overwrite the messages a member has not read.
+		for message.clock-member.clock.Load() >= r.size() {
+			r = r.grow()
+			g.ring.Store(r)
+		}
+	}
+	g.observeEnqueue(r, message)
+	r.put(message)
+	g.head.Store(message.clock + 1)
+	for _, member := range members {
+		signal(member.wake)
 	}
 }
 
 // Send broadcasts a message to every one of a Group's members.
-func (g *Group) Send(val interface{}) {
-	g.in <- Message{sender: nil, payload: val}
+// It returns ErrGroupClosed if the group is closed.
+func (g *TypedGroup[T]) Send(val T, opts ...SendOption) error {
+	return g.send(context.Background(), newMessage(nil, val, opts))
+}
+
+// SendContext broadcasts a message to every one of a Group's members.
+// It gives up and returns ctx.Err() if the message was not accepted by
+// the dispatcher before ctx is done.
+func (g *TypedGroup[T]) SendContext(ctx context.Context, val T, opts ...SendOption) error {
+	return g.send(ctx, newMessage(nil, val, opts))
+}
+
+// sentClock returns the group clock after all the messages accepted
+// by the dispatchers are stamped.
+func (g *TypedGroup[T]) sentClock() int64 {
+	g.clockLock.Lock()
+	defer g.clockLock.Unlock()
+	for g.unstamped != 0 {
+		g.stamped.Wait()
+	}
+	return g.clock
+}
+
+func (g *TypedGroup[T]) send(ctx context.Context, message Message[T]) error {
+	if g.opts.tracer != nil {
+		span := g.traceEnqueue(ctx, &message)
+		defer span.End()
+	}
+	select {
+	case g.in <- message:
+		g.observeSend(message.sender)
+		g.clockLock.Lock()
+		g.unstamped++
+		if g.unstamped == 0 {
+			g.stamped.Broadcast()
+		}
+		g.clockLock.Unlock()
+		return nil
+	case <-g.quit:
+		return ErrGroupClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Close removes the member it is called on from its broadcast group
 // and closes Read channel.
-func (m *Member) Close() {
+func (m *TypedMember[T]) Close() {
 	m.group.Leave(m)
 }
 
 // Send broadcasts a message from one Member to the channels of all
-// the other members in its group.
-func (m *Member) Send(val interface{}) {
-	m.group.in <- Message{sender: m, payload: val}
+// the other members in its group. It returns ErrGroupClosed if the
+// group is closed.
+func (m *TypedMember[T]) Send(val T, opts ...SendOption) error {
+	return m.group.send(context.Background(), newMessage(m, val, opts))
+}
+
+// SendContext broadcasts a message from one Member to the channels
+// of all the other members in its group. It gives up and returns
+// ctx.Err() if the message was not accepted by the dispatcher before
+// ctx is done.
+func (m *TypedMember[T]) SendContext(ctx context.Context, val T, opts ...SendOption) error {
+	return m.group.send(ctx, newMessage(m, val, opts))
+}
+
+// ID returns the identifier of the member in its group. It doesn't
+// change while the member stays in the group and is not reused for
+// other members.
+func (m *TypedMember[T]) ID() MemberID {
+	return m.id
+}
+
+// Name returns the name of the member set by WithName option.
+func (m *TypedMember[T]) Name() string {
+	return m.opts.name
+}
+
+// Drain removes the member from its group and returns the messages
+// the group has sent before the call but the member has not read yet
+// instead of delivering them to the Read channel.
+func (m *TypedMember[T]) Drain() []T {
+	undelivered, _ := m.group.leave(m, true, nil, true)
+	return undelivered
+}
+
+// SetFilter replaces the filter of the member. Only the messages
+// accepted by the filter are delivered to the member, nil filter
+// accepts all of them. The clock of the member advances for the
+// filtered out messages the same way as for the delivered ones, so
+// the order of the delivered messages is kept.
+func (m *TypedMember[T]) SetFilter(filter func(payload T) bool) {
+	if filter == nil {
+		m.filter.Store(nil)
+		return
+	}
+	m.filter.Store(&filter)
+}
+
+// Drops returns the number of messages the member has lost because
+// of its buffer overflow.
+func (m *TypedMember[T]) Drops() int {
+	return int(m.drops.Load())
+}
+
+// Evicted reports whether the member was removed from the group
+// because of its buffer overflow.
+func (m *TypedMember[T]) Evicted() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.evicted
 }
 
 // Recv reads one value from the member's Read channel
-func (m *Member) Recv() interface{} {
+func (m *TypedMember[T]) Recv() T {
 	return <-m.Read
 }
 
-func (m *Member) listen() {
-	for {
-		select {
-		case message := <-m.send:
-			m.handleMessage(&message)
-		case <-m.close:
-			return
+// RecvContext reads one value from the member's Read channel. It
+// returns ctx.Err() if no value arrived before ctx is done and
+// ErrMemberClosed if the member has left the group.
+func (m *TypedMember[T]) RecvContext(ctx context.Context) (T, error) {
+	select {
+	case val, ok := <-m.Read:
+		if !ok {
+			return val, ErrMemberClosed
 		}
+		return val, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
 	}
 }
 
-func (m *Member) handleMessage(message *Message) {
-	if !m.trySend(message) {
-		heap.Push(&m.messageQueue, &Item{
-			priority: message.clock,
-			value:    message,
-		})
-		return
+// stop makes the listen goroutine exit after it delivers messages
+// with clocks below drainUntil. Negative drainUntil drops them. When
+// expired fires or collect is set the rest of the messages are kept
+// in undelivered instead.
+func (m *TypedMember[T]) stop(drainUntil int64, expired <-chan time.Time, collect bool) {
+	m.drainUntil = drainUntil
+	m.expired = expired
+	m.collect = collect
+	close(m.quit)
+}
+
+func (m *TypedMember[T]) listen() {
+	defer close(m.done)
+	defer close(m.read)
+	defer m.flushBatch(true)
+	if m.requests != nil {
+		defer close(m.requests)
+	}
+	if m.envelopes != nil {
+		defer close(m.envelopes)
 	}
-	if m.messageQueue.Len() > 0 {
-		nextMessage := m.messageQueue[0].value.(*Message)
-		for m.trySend(nextMessage) {
-			heap.Pop(&m.messageQueue)
-			if m.messageQueue.Len() > 0 {
-				nextMessage = m.messageQueue[0].value.(*Message)
+	for _, message := range m.replay {
+		if m.wants(message) && !m.expire(message) {
+			m.deliver(message)
+		}
+	}
+	m.replay = nil
+	quit := m.quit
+	var retry <-chan time.Time
+	if m.opts.durable != nil {
+		defer m.opts.durable.stop()
+	}
+	for !m.dropping {
+		if m.opts.durable != nil && quit != nil {
+			for _, message := range m.opts.durable.redeliveries() {
+				message := message.(*Message[T])
+				if m.expire(message) {
+					m.opts.durable.forget(message.clock)
+					continue
+				}
+				m.deliver(message)
+			}
+			retry = m.opts.durable.retry()
+		}
+		if message := m.next(); message != nil {
+			if message.call != nil {
+				m.flushBatch(true)
+				m.deliverRequest(message)
 			} else {
-				break
+				m.deliver(message)
+			}
+			continue
+		}
+		m.flushBatch(false)
+		if quit == nil && m.clock.Load() >= m.drainUntil {
+			return
+		}
+		select {
+		case <-m.wake:
+		case b := <-m.batches:
+			m.flushBatch(true)
+			m.batch = b
+		case <-retry:
+			m.opts.durable.fired()
+		case <-quit:
+			if m.drainUntil < 0 {
+				return
 			}
+			quit = nil
+		}
+	}
+}
+
+// makeRoom applies the overflow policy of the member before the
+// message with the clock is published. It is called by the
+// dispatcher and returns false if the member must be evicted.
+func (m *TypedMember[T]) makeRoom(message *Message[T]) bool {
+	size := int64(m.opts.bufferSize)
+	if size == 0 {
+		return true
+	}
+	clock := message.clock
+	// The messages taken to the priority queue are older than the
+	// ones waiting in the ring.
+	for clock-m.clock.Load()+m.queued.Load() >= size {
+		switch m.opts.overflowPolicy {
+		case DropOldest:
+			m.lock.Lock()
+			if m.lanes.Len() > 0 {
+				dropped := m.dequeue(m.lanes.oldest())
+				m.lock.Unlock()
+				m.observeDrop(dropped.clock)
+				continue
+			}
+			cursor := m.clock.Load()
+			dropped := m.group.ring.Load().get(cursor)
+			m.clock.Store(cursor + 1)
+			m.lock.Unlock()
+			// The filter is called without the lock held.
+			if dropped != nil && m.wants(dropped) {
+				m.observeDrop(dropped.clock)
+			}
+		case DropNewest:
+			m.lock.Lock()
+			m.skipped = append(m.skipped, clock)
+			m.lock.Unlock()
+			if m.wants(message) {
+				m.observeDrop(clock)
+			}
+			return true
+		case Evict:
+			return false
+		case Block:
+			select {
+			case <-m.room:
+				continue
+			case <-m.quit:
+			case <-m.group.quit:
+			}
+			// The member or the whole group is closing, publish
+			// the message anyway to let them drain.
+			return true
+		}
+	}
+	return true
+}
+
+// wants reports whether the message should be delivered to the
+// member.
+func (m *TypedMember[T]) wants(message *Message[T]) bool {
+	if message.sender == m && (!m.opts.echo || message.call != nil) {
+		return false
+	}
+	if message.call != nil && m.requests == nil {
+		return false
+	}
+	if message.where != nil && !message.where(m) {
+		return false
+	}
+	if message.topic != "" && !m.subscribed(message.topic) {
+		return false
+	}
+	if filter := m.filter.Load(); filter != nil {
+		return (*filter)(message.payload)
+	}
+	return true
+}
+
+// nextMessage takes the message at the cursor of the member from the
+// ring, skipping dropped ones. It returns nil if the message has not
+// been published yet.
+func (m *TypedMember[T]) nextMessage() *Message[T] {
+	var lost []int64
+	m.lock.Lock()
+	defer func() {
+		m.lock.Unlock()
+		// The observers are called without the lock held.
+		for _, clock := range lost {
+			m.observeDrop(clock)
+		}
+	}()
+	for {
+		clock := m.clock.Load()
+		if clock >= m.group.head.Load() {
+			return nil
 		}
+		message := m.group.ring.Load().get(clock)
+		m.clock.Store(clock + 1)
+		if m.opts.bufferSize > 0 {
+			signal(m.room)
+		}
+		if len(m.skipped) > 0 && m.skipped[0] == clock {
+			m.skipped = m.skipped[1:]
+			continue
+		}
+		if message == nil {
+			// Overwritten, it never happens while the member
+			// holds the dispatcher.
+			lost = append(lost, clock)
+			continue
+		}
+		return message
 	}
 }
 
-func (m *Member) trySend(message *Message) bool {
-	shouldSend := message.clock == m.clock
-	if shouldSend {
-		if message.sender != m {
-			m.Read <- message.payload
+// deliver writes the payload to the Read channel or the envelope to
+// the Envelopes channel unless the member is stopped and has nothing
+// to drain or is collecting undelivered values.
+func (m *TypedMember[T]) deliver(message *Message[T]) {
+	m.received.Add(1)
+	var (
+		ctx  context.Context
+		span Span
+	)
+	if m.group.opts.tracer != nil {
+		ctx, span = m.traceDeliver(message)
+	}
+	var delivered bool
+	if m.envelopes != nil {
+		envelope := message.envelope()
+		envelope.Context = ctx
+		delivered = sendTo(m, m.envelopes, envelope)
+	} else {
+		delivered = m.sendValue(message.payload)
+	}
+	if span != nil {
+		span.End()
+	}
+	if delivered {
+		if m.opts.durable != nil {
+			m.opts.durable.track(message.clock, message)
 		}
-		m.clock++
+		m.observeDeliver(message)
+	} else if m.collect {
+		m.undelivered = append(m.undelivered, message.payload)
+	}
+}
+
+// sendTo writes the value to the channel of the member. It returns
+// false if the value was dropped or should be collected instead.
+func sendTo[T, V any](m *TypedMember[T], c chan V, val V) bool {
+	if m.dropping {
+		return false
+	}
+	select {
+	case c <- val:
+		return true
+	case <-m.quit:
+	}
+	if m.drainUntil < 0 {
+		m.dropping = true
+		return false
+	}
+	if !m.collect {
+		select {
+		case c <- val:
+			return true
+		case <-m.expired:
+			m.collect = true
+		}
+	}
+	return false
+}
+
+// signal wakes up a goroutine waiting on the channel of capacity 1
+// without blocking the caller.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
 	}
-	return shouldSend
 }
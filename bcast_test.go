@@ -10,6 +10,7 @@ package bcast
 */
 
 import (
+	"context"
 	"gopkg.in/fatih/set.v0"
 	"testing"
 	"time"
@@ -66,13 +67,13 @@ func TestMemberBroadcast(t *testing.T) {
 			} else {
 				val := m.Recv()
 				if val != broadcaster {
-					t.Fatal("incorrect message received")
+					t.Error("incorrect message received")
 				}
 			}
 			channel <- true
 			val := m.Recv()
 			if val != "done" {
-				t.Fatal("incorrect message received")
+				t.Error("incorrect message received")
 			}
 			channel <- true
 		}(i, group, c, m)
@@ -106,7 +107,7 @@ func TestGroupBroadcast(t *testing.T) {
 		go func(i int, group *Group, channel chan bool, member *Member) {
 			val := m.Recv()
 			if val != "group message" {
-				t.Fatal("incorrect message received")
+				t.Error("incorrect message received")
 			}
 			channel <- true
 		}(i, group, c, m)
@@ -144,7 +145,8 @@ func TestBroadcastOnLargeNumberOfMembers(t *testing.T) {
 			for {
 				newValue := m.Recv()
 				if encountered.Has(newValue) {
-					t.Fatal("Received duplicate value")
+					t.Error("Received duplicate value")
+					break
 				}
 				encountered.Add(newValue)
 				if encountered.IsEqual(expected) {
@@ -159,3 +161,414 @@ func TestBroadcastOnLargeNumberOfMembers(t *testing.T) {
 		<-channel
 	}
 }
+
+// Create new typed broadcast group.
+// Join 3 members.
+// Make group broadcast typed values in order.
+func TestTypedGroup(t *testing.T) {
+	type point struct{ x, y int }
+	group := NewGroupOf[point]()
+	var members []*TypedMember[point]
+	for i := 0; i < 3; i++ {
+		members = append(members, group.Join())
+	}
+	go group.Broadcast(0)
+	go func() {
+		for i := 0; i < 10; i++ {
+			group.Send(point{i, -i})
+		}
+	}()
+	for _, m := range members {
+		for i := 0; i < 10; i++ {
+			if p := m.Recv(); p.x != i || p.y != -i {
+				t.Fatalf("expected %v, got %v", point{i, -i}, p)
+			}
+		}
+	}
+}
+
+// Create new untyped broadcast group.
+// Add a member for the channel made by the caller.
+// Check that the untyped API keeps its types.
+func TestUntypedGroup(t *testing.T) {
+	var group *Group = NewGroup()
+	channel := make(chan interface{})
+	var member *Member = group.Add(channel)
+	var read chan interface{} = member.Read
+	if read != channel {
+		t.Fatal("member must read the channel it was added for")
+	}
+	go group.Broadcast(0)
+	group.Send("test message")
+	if val := <-read; val != "test message" {
+		t.Fatalf("unexpected value %v", val)
+	}
+	group.Close()
+}
+
+// Create new broadcast group without running dispatcher.
+// Check that sending and receiving give up on cancelled context.
+// Check that the dispatcher stops on cancelled context.
+func TestContextCancel(t *testing.T) {
+	group := NewGroup()
+	member := group.Join()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := member.SendContext(ctx, "lost"); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error on send, got %v", err)
+	}
+	if err := group.SendContext(ctx, "lost"); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error on group send, got %v", err)
+	}
+	if _, err := member.RecvContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error on receive, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- group.BroadcastContext(ctx) }()
+	if err := group.SendContext(ctx, "delivered"); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := member.RecvContext(ctx); err != nil || val != "delivered" {
+		t.Fatalf("expected delivered message, got %v, %v", val, err)
+	}
+	cancel()
+	if err := <-stopped; err != context.Canceled {
+		t.Fatalf("expected dispatcher stopped by context, got %v", err)
+	}
+}
+
+// Create new broadcast group without running dispatcher.
+// Close it twice.
+// Check that members are closed and sending fails.
+func TestClose(t *testing.T) {
+	group := NewGroup()
+	member := group.Join()
+	group.Close()
+	group.Close()
+	if _, ok := <-member.Read; ok {
+		t.Fatal("Read channel must be closed")
+	}
+	if err := group.Send("lost"); err != ErrGroupClosed {
+		t.Fatalf("expected ErrGroupClosed, got %v", err)
+	}
+	if err := member.Send("lost"); err != ErrGroupClosed {
+		t.Fatalf("expected ErrGroupClosed, got %v", err)
+	}
+	if group.MemberCount() != 0 {
+		t.Fatal("closed group must not have members")
+	}
+	if _, ok := <-group.Join().Read; ok {
+		t.Fatal("member joined to closed group must be closed")
+	}
+}
+
+// Create new broadcast group with drain policy.
+// Send messages nobody reads yet and close the group.
+// Check that all messages delivered before Read is closed.
+func TestCloseDrain(t *testing.T) {
+	const max = 10
+	group := NewGroup(WithClosePolicy(DrainOnClose))
+	member := group.Join()
+	go group.Broadcast(0)
+	for i := 0; i < max; i++ {
+		group.Send(i)
+	}
+	group.Close()
+	received := 0
+	for val := range member.Read {
+		if val != received {
+			t.Fatalf("expected %d, got %v", received, val)
+		}
+		received++
+	}
+	if received != max {
+		t.Fatalf("expected %d messages drained, got %d", max, received)
+	}
+}
+
+// Create new broadcast group.
+// Send messages nobody reads yet.
+// Leave gracefully and check that all messages delivered in order.
+func TestLeaveGraceful(t *testing.T) {
+	const max = 10
+	group := NewGroup()
+	member := group.Join()
+	go group.Broadcast(0)
+	for i := 0; i < max; i++ {
+		group.Send(i)
+	}
+	done := make(chan []interface{})
+	go func() {
+		undelivered, err := group.LeaveGraceful(member, 0)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- undelivered
+	}()
+	received := 0
+	for val := range member.Read {
+		if val != received {
+			t.Fatalf("expected %d, got %v", received, val)
+		}
+		received++
+	}
+	if received != max {
+		t.Fatalf("expected %d messages delivered, got %d", max, received)
+	}
+	if undelivered := <-done; len(undelivered) != 0 {
+		t.Fatalf("expected nothing undelivered, got %v", undelivered)
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Send messages nobody reads and let the graceful leave time out.
+// Drain another member and check that the messages returned in order.
+func TestLeaveGracefulUndelivered(t *testing.T) {
+	const max = 10
+	group := NewGroup()
+	member1 := group.Join()
+	member2 := group.Join()
+	go group.Broadcast(0)
+	for i := 0; i < max; i++ {
+		group.Send(i)
+	}
+	undelivered, err := group.LeaveGraceful(member1, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undelivered) != max {
+		t.Fatalf("expected %d messages undelivered, got %v", max, undelivered)
+	}
+	if val := member2.Recv(); val != 0 {
+		t.Fatalf("expected 0, got %v", val)
+	}
+	undelivered = member2.Drain()
+	if len(undelivered) != max-1 {
+		t.Fatalf("expected %d messages drained, got %v", max-1, undelivered)
+	}
+	for i, val := range undelivered {
+		if val != i+1 {
+			t.Fatalf("expected %d, got %v", i+1, val)
+		}
+	}
+	if _, ok := <-member2.Read; ok {
+		t.Fatal("Read channel must be closed")
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join members with small buffers and different overflow policies.
+// Overflow the buffers and check what is delivered and dropped.
+func TestOverflowPolicies(t *testing.T) {
+	const max, size = 10, 3
+	group := NewGroup()
+	oldest := group.JoinWithOptions(WithBufferSize(size), WithOverflowPolicy(DropOldest))
+	newest := group.JoinWithOptions(WithBufferSize(size), WithOverflowPolicy(DropNewest))
+	evicted := group.JoinWithOptions(WithBufferSize(size), WithOverflowPolicy(Evict))
+	go group.Broadcast(0)
+	// Let the listen goroutines take the first message and block
+	// on Read, so exactly size messages more stay in the buffers.
+	group.Send(0)
+	time.Sleep(50 * time.Millisecond)
+	for i := 1; i < max; i++ {
+		group.Send(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := <-evicted.Read; ok || !evicted.Evicted() {
+		t.Fatal("member must be evicted")
+	}
+	if group.MemberCount() != 2 {
+		t.Fatal("evicted member must leave the group")
+	}
+	check := func(member *Member, expected ...int) {
+		for _, e := range expected {
+			if val := member.Recv(); val != e {
+				t.Fatalf("expected %d, got %v", e, val)
+			}
+		}
+		if member.Drops() != max-1-size {
+			t.Fatalf("expected %d drops, got %d", max-1-size, member.Drops())
+		}
+	}
+	check(oldest, 0, 7, 8, 9)
+	check(newest, 0, 1, 2, 3)
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join member with blocking buffer of size 1.
+// Check that the dispatcher waits for the member.
+func TestOverflowBlock(t *testing.T) {
+	const max = 10
+	group := NewGroup()
+	member := group.JoinWithOptions(WithBufferSize(1), WithOverflowPolicy(Block))
+	go group.Broadcast(0)
+	go func() {
+		for i := 0; i < max; i++ {
+			group.Send(i)
+		}
+	}()
+	for i := 0; i < max; i++ {
+		time.Sleep(time.Millisecond)
+		if val := member.Recv(); val != i {
+			t.Fatalf("expected %d, got %v", i, val)
+		}
+	}
+	if member.Drops() != 0 {
+		t.Fatal("blocking member must not drop messages")
+	}
+	group.Close()
+}
+
+// Create new broadcast group with tiny ring buffer.
+// Send more messages than the ring holds while nobody reads.
+// Check that the ring grows and no message is lost.
+func TestRingGrowth(t *testing.T) {
+	const max = 100
+	group := NewGroup(WithRingSize(4))
+	member := group.Join()
+	go group.Broadcast(0)
+	for i := 0; i < max; i++ {
+		group.Send(i)
+	}
+	for i := 0; i < max; i++ {
+		if val := member.Recv(); val != i {
+			t.Fatalf("expected %d, got %v", i, val)
+		}
+	}
+	if member.Drops() != 0 {
+		t.Fatal("member with unlimited buffer must not drop messages")
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join member which accepts only even numbers.
+// Replace the filter and check that both filters applied in order.
+func TestFilter(t *testing.T) {
+	const max = 10
+	group := NewGroupOf[int]()
+	member := group.JoinFiltered(func(val int) bool { return val%2 == 0 })
+	go group.Broadcast(0)
+	// The last message is even, so nothing is left to filter when
+	// the filter replaced.
+	for i := 0; i <= max; i++ {
+		group.Send(i)
+	}
+	for i := 0; i <= max; i += 2 {
+		if val := member.Recv(); val != i {
+			t.Fatalf("expected %d, got %d", i, val)
+		}
+	}
+	member.SetFilter(func(val int) bool { return val%2 == 1 })
+	for i := 0; i < max; i++ {
+		group.Send(i)
+	}
+	for i := 1; i < max; i += 2 {
+		if val := member.Recv(); val != i {
+			t.Fatalf("expected %d, got %d", i, val)
+		}
+	}
+	member.SetFilter(nil)
+	group.Send(max)
+	if val := member.Recv(); val != max {
+		t.Fatalf("expected %d, got %d", max, val)
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join 5 named members and remove some of them.
+// Check that lookup by ID finds only the remaining members.
+func TestMemberLookup(t *testing.T) {
+	group := NewGroup()
+	var members []*Member
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		members = append(members, group.JoinWithOptions(WithName(name)))
+	}
+	snapshot := group.Members()
+	members[1].Close()
+	members[0].Close()
+	if len(snapshot) != 5 {
+		t.Fatal("Members must return a copy")
+	}
+	if err := group.Leave(members[0]); err != ErrMemberNotFound {
+		t.Fatalf("expected ErrMemberNotFound, got %v", err)
+	}
+	for i, member := range members {
+		found, ok := group.Member(member.ID())
+		if ok != (i >= 2) {
+			t.Fatalf("member %s: expected found %v", member.Name(), i >= 2)
+		}
+		if ok && (found != member || found.Name() != member.Name()) {
+			t.Fatalf("member %s: found another member %s", member.Name(), found.Name())
+		}
+	}
+	if group.MemberCount() != 3 {
+		t.Fatalf("expected 3 members, got %d", group.MemberCount())
+	}
+	if next := group.Join(); next.ID() <= members[4].ID() {
+		t.Fatal("IDs must not be reused")
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join member with echo and member without it.
+// Check that only the first one receives own messages in order.
+func TestEcho(t *testing.T) {
+	group := NewGroupOf[string]()
+	echo := group.JoinWithOptions(WithEcho())
+	plain := group.Join()
+	go group.Broadcast(0)
+	echo.Send("echo 1")
+	plain.Send("plain")
+	echo.Send("echo 2")
+	for _, expected := range []string{"echo 1", "plain", "echo 2"} {
+		if val := echo.Recv(); val != expected {
+			t.Fatalf("expected %q, got %q", expected, val)
+		}
+	}
+	for _, expected := range []string{"echo 1", "echo 2"} {
+		if val := plain.Recv(); val != expected {
+			t.Fatalf("expected %q, got %q", expected, val)
+		}
+	}
+	group.Close()
+}
+
+// benchmarkBroadcast measures the delivery of one message to all the
+// members, compare it with the delivery paths in baseline_test.go.
+func benchmarkBroadcast(b *testing.B, members int) {
+	group := NewGroupOf[int]()
+	done := make(chan bool)
+	for i := 0; i < members; i++ {
+		m := group.Join()
+		go func() {
+			for n := 0; n < b.N; n++ {
+				m.Recv()
+			}
+			done <- true
+		}()
+	}
+	go group.Broadcast(0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		group.Send(n)
+	}
+	for i := 0; i < members; i++ {
+		<-done
+	}
+	b.StopTimer()
+	group.Close()
+}
+
+func BenchmarkBroadcast10(b *testing.B)  { benchmarkBroadcast(b, 10) }
+func BenchmarkBroadcast100(b *testing.B) { benchmarkBroadcast(b, 100) }
+func BenchmarkBroadcast500(b *testing.B) { benchmarkBroadcast(b, 500) }
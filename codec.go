@@ -0,0 +1,304 @@
+package bcast
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnregisteredType is returned when a payload of the type
+	// unknown to the registry is encoded or decoded.
+	ErrUnregisteredType = errors.New("Type is not registered")
+	// ErrMalformedEnvelope is returned when the encoded envelope is
+	// truncated or corrupted.
+	ErrMalformedEnvelope = errors.New("Malformed envelope")
+)
+
+// Codec encodes the payloads for the transports and the persistence.
+// Both methods get a pointer to the value of the type of the group.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// GobCodec encodes the payloads with encoding/gob. Concrete types of
+// the payloads of the groups of interface types must be registered
+// with gob.Register.
+type GobCodec struct{}
+
+// Marshal encodes the value with gob.
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the value encoded by Marshal.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec encodes the payloads with encoding/json. Payloads of the
+// groups of interface types are decoded as the generic JSON values
+// unless the codec is wrapped by a Registry.
+type JSONCodec struct{}
+
+// Marshal encodes the value as JSON.
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes the JSON into the value.
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// RawCodec passes the payloads of []byte type as is.
+type RawCodec struct{}
+
+// Marshal returns the bytes of the payload. The payload must be
+// []byte, a pointer to it or a pointer to an interface holding it.
+func (RawCodec) Marshal(v any) ([]byte, error) {
+	switch p := v.(type) {
+	case []byte:
+		return p, nil
+	case *[]byte:
+		return *p, nil
+	case *any:
+		if data, ok := (*p).([]byte); ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("bcast: raw codec cannot encode %T", *p)
+	}
+	return nil, fmt.Errorf("bcast: raw codec cannot encode %T", v)
+}
+
+// Unmarshal stores a copy of the data to the payload pointed by v.
+func (RawCodec) Unmarshal(data []byte, v any) error {
+	data = append([]byte(nil), data...)
+	switch p := v.(type) {
+	case *[]byte:
+		*p = data
+	case *any:
+		*p = data
+	default:
+		return fmt.Errorf("bcast: raw codec cannot decode into %T", v)
+	}
+	return nil
+}
+
+// Registry maps the names to the payload types, so the payloads of
+// the groups of interface types keep their concrete types after a
+// round trip through a codec.
+type Registry struct {
+	lock  sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		types: make(map[string]reflect.Type),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// Register adds the type of the sample value under the name. The name
+// is written to the wire, so it must stay the same for the peers.
+func (r *Registry) Register(name string, sample any) {
+	t := reflect.TypeOf(sample)
+	r.lock.Lock()
+	r.types[name] = t
+	r.names[t] = name
+	r.lock.Unlock()
+}
+
+// Codec wraps the codec, so it writes the registered name of the type
+// before the payload and decodes the payload into the value of that
+// type.
+func (r *Registry) Codec(inner Codec) Codec {
+	return &typedCodec{registry: r, inner: inner}
+}
+
+type typedCodec struct {
+	registry *Registry
+	inner    Codec
+}
+
+func (c *typedCodec) Marshal(v any) ([]byte, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil, fmt.Errorf("bcast: cannot encode nil %T", v)
+	}
+	t := value.Type()
+	// The inner codec gets a pointer to the concrete value.
+	concrete := reflect.New(t)
+	concrete.Elem().Set(value)
+	c.registry.lock.RLock()
+	name, ok := c.registry.names[t]
+	c.registry.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnregisteredType, t)
+	}
+	data, err := c.inner.Marshal(concrete.Interface())
+	if err != nil {
+		return nil, err
+	}
+	buf := binary.AppendUvarint(nil, uint64(len(name)))
+	buf = append(buf, name...)
+	return append(buf, data...), nil
+}
+
+func (c *typedCodec) Unmarshal(data []byte, v any) error {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < size {
+		return ErrMalformedEnvelope
+	}
+	name := string(data[n : n+int(size)])
+	c.registry.lock.RLock()
+	t, ok := c.registry.types[name]
+	c.registry.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnregisteredType, name)
+	}
+	value := reflect.New(t)
+	if err := c.inner.Unmarshal(data[n+int(size):], value.Interface()); err != nil {
+		return err
+	}
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("bcast: cannot decode into %T", v)
+	}
+	if !t.AssignableTo(target.Elem().Type()) {
+		return fmt.Errorf("bcast: cannot decode %s into %T", t, v)
+	}
+	target.Elem().Set(value.Elem())
+	return nil
+}
+
+const (
+	// envelopeVersion is the first byte of an encoded envelope.
+	envelopeVersion = 2
+	// envelopeVersionNoDeadline is the version of the envelopes
+	// encoded without the deadline, they are still decoded.
+	envelopeVersionNoDeadline = 1
+)
+
+// MarshalEnvelope encodes the envelope together with its metadata:
+// the clock, the sender, the send time, the deadline, the topic and
+// the headers. The payload is encoded by the codec.
+func MarshalEnvelope[T any](codec Codec, envelope Envelope[T]) ([]byte, error) {
+	payload, err := codec.Marshal(&envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+	buf := []byte{envelopeVersion}
+	buf = binary.AppendVarint(buf, envelope.Clock)
+	buf = binary.AppendUvarint(buf, uint64(envelope.Sender))
+	var sent int64
+	if !envelope.Time.IsZero() {
+		sent = envelope.Time.UnixNano()
+	}
+	buf = binary.AppendVarint(buf, sent)
+	var deadline int64
+	if !envelope.Deadline.IsZero() {
+		deadline = envelope.Deadline.UnixNano()
+	}
+	buf = binary.AppendVarint(buf, deadline)
+	buf = appendString(buf, envelope.Topic)
+	buf = binary.AppendUvarint(buf, uint64(len(envelope.Headers)))
+	for key, value := range envelope.Headers {
+		buf = appendString(buf, key)
+		buf = appendString(buf, value)
+	}
+	return append(buf, payload...), nil
+}
+
+// UnmarshalEnvelope decodes the envelope encoded by MarshalEnvelope.
+func UnmarshalEnvelope[T any](codec Codec, data []byte) (Envelope[T], error) {
+	var envelope Envelope[T]
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil || version != envelopeVersion && version != envelopeVersionNoDeadline {
+		return envelope, ErrMalformedEnvelope
+	}
+	if envelope.Clock, err = binary.ReadVarint(r); err != nil {
+		return envelope, ErrMalformedEnvelope
+	}
+	sender, err := binary.ReadUvarint(r)
+	if err != nil {
+		return envelope, ErrMalformedEnvelope
+	}
+	envelope.Sender = MemberID(sender)
+	sent, err := binary.ReadVarint(r)
+	if err != nil {
+		return envelope, ErrMalformedEnvelope
+	}
+	if sent != 0 {
+		envelope.Time = time.Unix(0, sent)
+	}
+	if version == envelopeVersion {
+		deadline, err := binary.ReadVarint(r)
+		if err != nil {
+			return envelope, ErrMalformedEnvelope
+		}
+		if deadline != 0 {
+			envelope.Deadline = time.Unix(0, deadline)
+		}
+	}
+	if envelope.Topic, err = readString(r); err != nil {
+		return envelope, err
+	}
+	headers, err := binary.ReadUvarint(r)
+	if err != nil || headers > uint64(r.Len()) {
+		return envelope, ErrMalformedEnvelope
+	}
+	if headers > 0 {
+		envelope.Headers = make(map[string]string, headers)
+	}
+	for i := uint64(0); i < headers; i++ {
+		key, err := readString(r)
+		if err != nil {
+			return envelope, err
+		}
+		if envelope.Headers[key], err = readString(r); err != nil {
+			return envelope, err
+		}
+	}
+	err = codec.Unmarshal(data[len(data)-r.Len():], &envelope.Payload)
+	return envelope, err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > uint64(r.Len()) {
+		return "", ErrMalformedEnvelope
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", ErrMalformedEnvelope
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,86 @@
+package bcast
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type tick struct {
+	Symbol string
+	Price  float64
+}
+
+// Encode envelopes of a typed payload with every codec.
+// Check that the payload and the metadata survive the round trip.
+func TestEnvelopeRoundTrip(t *testing.T) {
+	sent := Envelope[tick]{
+		Sender:   3,
+		Clock:    42,
+		Time:     time.Unix(0, 1234567890),
+		Deadline: time.Unix(0, 2234567890),
+		Topic:    "prices.eu",
+		Headers:  map[string]string{"trace-id": "abc"},
+		Payload:  tick{"EUR", 1.08},
+	}
+	for _, codec := range []Codec{GobCodec{}, JSONCodec{}} {
+		data, err := MarshalEnvelope(codec, sent)
+		if err != nil {
+			t.Fatalf("%T: %v", codec, err)
+		}
+		received, err := UnmarshalEnvelope[tick](codec, data)
+		if err != nil {
+			t.Fatalf("%T: %v", codec, err)
+		}
+		if !reflect.DeepEqual(sent, received) {
+			t.Fatalf("%T: sent %+v, received %+v", codec, sent, received)
+		}
+	}
+	if _, err := UnmarshalEnvelope[tick](JSONCodec{}, []byte{envelopeVersion, 1}); !errors.Is(err, ErrMalformedEnvelope) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// Encode envelopes of untyped payloads through a registry.
+// Check that the payloads keep their concrete types.
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("tick", tick{})
+	registry.Register("bytes", []byte(nil))
+	for _, codec := range []Codec{registry.Codec(GobCodec{}), registry.Codec(JSONCodec{})} {
+		for _, payload := range []any{tick{"USD", 1}, []byte("raw")} {
+			data, err := MarshalEnvelope(codec, Envelope[any]{Clock: 1, Payload: payload})
+			if err != nil {
+				t.Fatalf("%T: %v", payload, err)
+			}
+			received, err := UnmarshalEnvelope[any](codec, data)
+			if err != nil {
+				t.Fatalf("%T: %v", payload, err)
+			}
+			if !reflect.DeepEqual(received.Payload, payload) {
+				t.Fatalf("sent %#v, received %#v", payload, received.Payload)
+			}
+		}
+		if _, err := codec.Marshal(42); !errors.Is(err, ErrUnregisteredType) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+// Encode an envelope of bytes with the raw codec.
+// Check that the bytes are passed as is.
+func TestRawCodec(t *testing.T) {
+	data, err := MarshalEnvelope(RawCodec{}, Envelope[[]byte]{Payload: []byte("raw")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := UnmarshalEnvelope[[]byte](RawCodec{}, data)
+	if err != nil || string(received.Payload) != "raw" {
+		t.Fatalf("unexpected payload %q, error %v", received.Payload, err)
+	}
+	var raw RawCodec
+	if _, err := raw.Marshal(42); err == nil {
+		t.Fatal("int is encoded by the raw codec")
+	}
+}
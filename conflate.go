@@ -0,0 +1,56 @@
+package bcast
+
+import (
+	"container/heap"
+)
+
+// JoinConflating returns a new member object configured with the
+// provided options which keeps only the newest of the waiting
+// messages with the same key returned by the keyer. The member reads
+// the ring ahead and replaces an older message it has not delivered
+// yet with a newer one of the same key, so a slow reader of state
+// updates gets the latest values instead of the whole backlog. The
+// newest message takes the place in the group order, the delivered
+// clocks keep growing. Messages with empty keys and requests are never
+// conflated.
+func (g *TypedGroup[T]) JoinConflating(keyer Keyer[T], opts ...MemberOption) *TypedMember[T] {
+	memberChannel := make(chan T)
+	return g.add(memberChannel, nil, keyer, opts)
+}
+
+// enqueue puts the message taken from the ring to the priority queue
+// of the member replacing the waiting message with the same key. It
+// is called under the lock of the member.
+func (m *TypedMember[T]) enqueue(message *Message[T]) {
+	item := &laneItem[T]{message: message}
+	if m.conflate != nil && message.call == nil {
+		if key := m.conflate(message.payload); key != "" {
+			if m.keys == nil {
+				m.keys = make(map[string]*laneItem[T])
+			}
+			if old, ok := m.keys[key]; ok {
+				heap.Remove(&m.lanes, old.index)
+				m.conflated.Add(1)
+				m.group.conflated.Add(1)
+			}
+			m.keys[key] = item
+		}
+	}
+	heap.Push(&m.lanes, item)
+	m.queued.Store(int64(m.lanes.Len()))
+}
+
+// dequeue removes the item from the priority queue and from the keys
+// of the conflated messages. It is called under the lock of the
+// member.
+func (m *TypedMember[T]) dequeue(index int) *Message[T] {
+	item := heap.Remove(&m.lanes, index).(*laneItem[T])
+	m.queued.Store(int64(m.lanes.Len()))
+	message := item.message
+	if m.keys != nil && message.call == nil {
+		if key := m.conflate(message.payload); m.keys[key] == item {
+			delete(m.keys, key)
+		}
+	}
+	return message
+}
@@ -0,0 +1,29 @@
+package bcast
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Create new broadcast group and join a conflating member.
+// Send several versions of two keys while the member does not read.
+// Check that only the newest versions are delivered in the group order.
+func TestConflation(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.JoinConflating(func(payload int) string {
+		return strconv.Itoa(payload / 10)
+	})
+	go group.Broadcast(0)
+	sendHeld(t, group, func() {
+		for _, val := range []int{11, 21, 12, 13, 22} {
+			group.Send(val)
+		}
+	})
+	expectOrder(t, member, -1, 13, 22)
+	group.Send(14)
+	expectOrder(t, member, 14)
+	if stats := group.Stats(); stats.Conflated != 3 || stats.Members[0].Conflated != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	group.Close()
+}
@@ -0,0 +1,34 @@
+package bcast
+
+import (
+	"context"
+)
+
+// SendTo sends a message from one Member to the members of its group
+// with the provided IDs only. The message goes through the group
+// sequence, so for the receivers it is ordered with the broadcasts
+// the same way as any other message. It returns ErrGroupClosed if the
+// group is closed.
+func (m *TypedMember[T]) SendTo(ids []MemberID, val T, opts ...SendOption) error {
+	targets := make(map[MemberID]bool, len(ids))
+	for _, id := range ids {
+		targets[id] = true
+	}
+	message := newMessage(m, val, opts)
+	message.where = func(member *TypedMember[T]) bool {
+		return targets[member.id]
+	}
+	return m.group.send(context.Background(), message)
+}
+
+// SendWhere sends a message to the members of the Group selected by
+// the function. The function is called for each member when the
+// message is delivered to it. The message goes through the group
+// sequence, so for the receivers it is ordered with the broadcasts
+// the same way as any other message. It returns ErrGroupClosed if the
+// group is closed.
+func (g *TypedGroup[T]) SendWhere(where func(*TypedMember[T]) bool, val T, opts ...SendOption) error {
+	message := newMessage(nil, val, opts)
+	message.where = where
+	return g.send(context.Background(), message)
+}
@@ -0,0 +1,33 @@
+package bcast
+
+import (
+	"testing"
+)
+
+// Create new broadcast group.
+// Send targeted messages between broadcasts.
+// Check that only the targets receive them in the group order.
+func TestSendTo(t *testing.T) {
+	group := NewGroupOf[string]()
+	sender := group.Join()
+	target := group.JoinWithOptions(WithName("target"))
+	other := group.JoinWithOptions(WithName("other"))
+	go group.Broadcast(0)
+
+	group.Send("first")
+	sender.SendTo([]MemberID{target.ID()}, "direct")
+	group.SendWhere(func(m *TypedMember[string]) bool { return m.Name() == "other" }, "selected")
+	group.Send("last")
+
+	expect := func(member *TypedMember[string], expected ...string) {
+		for _, e := range expected {
+			if val := member.Recv(); val != e {
+				t.Fatalf("%s: expected %q, got %q", member.Name(), e, val)
+			}
+		}
+	}
+	expect(target, "first", "direct", "last")
+	expect(other, "first", "selected", "last")
+	expect(sender, "first", "last")
+	group.Close()
+}
@@ -0,0 +1,266 @@
+package bcast
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAckTimeout is the time after which the messages delivered to
+// a durable member and not acknowledged are delivered again.
+const DefaultAckTimeout = 30 * time.Second
+
+var (
+	// ErrNoLog is returned when a durable member joins a group which
+	// was not created by NewGroupFromLog.
+	ErrNoLog = errors.New("Group has no log")
+	// ErrDurableActive is returned when a durable member joins under
+	// the name of a member which has not left the group yet.
+	ErrDurableActive = errors.New("Durable member is already joined")
+	// ErrInvalidName is returned when the name of a durable member
+	// can not be used as a file name.
+	ErrInvalidName = errors.New("Invalid durable member name")
+	// ErrNotDurable is returned when a member which is not durable
+	// acknowledges a message.
+	ErrNotDurable = errors.New("Member is not durable")
+)
+
+// WithAckTimeout sets the time after which the messages delivered to
+// a durable member and not acknowledged are delivered again.
+// DefaultAckTimeout is used by default.
+func WithAckTimeout(timeout time.Duration) MemberOption {
+	return func(o *memberOptions) {
+		o.ackTimeout = timeout
+	}
+}
+
+// durable keeps the position of a durable member. The position is
+// the clock of the first message not acknowledged, it is saved to a
+// file next to the log of the group.
+type durable struct {
+	name    string
+	path    string
+	timeout time.Duration
+	lock    sync.Mutex
+	acked   int64 // clock of the first message not acknowledged
+	pending []delivery
+	timer   *time.Timer // used by the listen goroutine only
+	armed   time.Time   // when the timer fires, zero if it is not armed
+}
+
+// delivery is a message delivered to the durable member and waiting
+// for the acknowledgement.
+type delivery struct {
+	clock   int64
+	message any // *Message[T] of the group
+	due     time.Time
+}
+
+// JoinDurable joins the durable member with the name to a group
+// created by NewGroupFromLog. The member resumes from the first
+// message it has not acknowledged with Member.Ack before it left or
+// its process stopped, the messages are replayed from the log. A
+// member joined under a new name starts from the messages sent after
+// it joined. Messages not acknowledged during the ack timeout are
+// delivered again, so every message is delivered at least once. The
+// messages sent by SendTo and SendWhere are logged with the names of
+// the durable members among their receivers and replayed to them
+// only, requests are never replayed. Durable members receive envelopes, which carry the clocks to
+// acknowledge, from Member.Envelopes.
+func (g *TypedGroup[T]) JoinDurable(name string, opts ...MemberOption) (*TypedMember[T], error) {
+	if g.log == nil {
+		return nil, ErrNoLog
+	}
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return nil, ErrInvalidName
+	}
+	dir := filepath.Join(g.log.dir, "durable")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &durable{name: name, path: filepath.Join(dir, name), timeout: DefaultAckTimeout}
+	var o memberOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.ackTimeout > 0 {
+		d.timeout = o.ackTimeout
+	}
+	saved, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	g.memberLock.Lock()
+	if g.durables == nil {
+		g.durables = make(map[string]*TypedMember[T])
+	}
+	if _, ok := g.durables[name]; ok {
+		g.memberLock.Unlock()
+		return nil, ErrDurableActive
+	}
+	// Reserve the name until the member joins.
+	g.durables[name] = nil
+	g.memberLock.Unlock()
+
+	opts = append(opts[:len(opts):len(opts)], WithEnvelopes(), withDurable(d))
+	if saved {
+		opts = append(opts, WithReplayFrom(d.acked))
+	}
+	member := g.JoinWithOptions(opts...)
+	g.memberLock.Lock()
+	if _, ok := g.index[member.id]; ok {
+		g.durables[name] = member
+	} else {
+		// Joined a closed group or left already.
+		delete(g.durables, name)
+	}
+	g.memberLock.Unlock()
+	if !saved {
+		d.lock.Lock()
+		d.acked = member.clock.Load()
+		err = d.save()
+		d.lock.Unlock()
+	}
+	return member, err
+}
+
+func withDurable(d *durable) MemberOption {
+	return func(o *memberOptions) {
+		o.durable = d
+	}
+}
+
+// Ack acknowledges the message with the clock and all the messages
+// before it, so the durable member resumes after them when it joins
+// again. The position is saved before Ack returns. It returns
+// ErrNotDurable for the members not joined by JoinDurable.
+func (m *TypedMember[T]) Ack(clock int64) error {
+	if m.opts.durable == nil {
+		return ErrNotDurable
+	}
+	return m.opts.durable.ack(clock)
+}
+
+func (d *durable) load() (bool, error) {
+	data, err := os.ReadFile(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	d.acked, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return err == nil, err
+}
+
+// save writes the position to a temporary file and renames it, so a
+// crash never leaves the position half written. It is called under
+// the lock.
+func (d *durable) save() error {
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(d.acked, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
+
+func (d *durable) ack(clock int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if clock < d.acked {
+		return nil
+	}
+	d.acked = clock + 1
+	acked := 0
+	for acked < len(d.pending) && d.pending[acked].clock <= clock {
+		acked++
+	}
+	d.pending = append(d.pending[:0], d.pending[acked:]...)
+	return d.save()
+}
+
+// track keeps the delivered message until it is acknowledged.
+func (d *durable) track(clock int64, message any) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if clock < d.acked {
+		return
+	}
+	if n := len(d.pending); n > 0 && clock <= d.pending[n-1].clock {
+		// Delivered again.
+		return
+	}
+	d.pending = append(d.pending, delivery{clock: clock, message: message, due: time.Now().Add(d.timeout)})
+}
+
+// forget stops delivering the expired message again. The position is
+// not moved, the log keeps the deadline of the message, so it is
+// skipped as expired when it is replayed after a restart.
+func (d *durable) forget(clock int64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := range d.pending {
+		if d.pending[i].clock == clock {
+			d.pending = append(d.pending[:i], d.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// redeliveries returns the messages to deliver again when the oldest
+// of them was not acknowledged in time.
+func (d *durable) redeliveries() []any {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if len(d.pending) == 0 || time.Now().Before(d.pending[0].due) {
+		return nil
+	}
+	due := time.Now().Add(d.timeout)
+	messages := make([]any, len(d.pending))
+	for i := range d.pending {
+		messages[i] = d.pending[i].message
+		d.pending[i].due = due
+	}
+	return messages
+}
+
+// retry returns the channel which fires when the oldest message is to
+// be delivered again or nil if nothing waits for the acknowledgement.
+// The caller must call fired after it receives from the channel.
+func (d *durable) retry() <-chan time.Time {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if len(d.pending) == 0 {
+		return nil
+	}
+	due := d.pending[0].due
+	switch {
+	case d.timer == nil:
+		d.timer = time.NewTimer(time.Until(due))
+	case !due.Equal(d.armed):
+		if !d.armed.IsZero() && !d.timer.Stop() {
+			<-d.timer.C
+		}
+		d.timer.Reset(time.Until(due))
+	}
+	d.armed = due
+	return d.timer.C
+}
+
+func (d *durable) fired() {
+	d.lock.Lock()
+	d.armed = time.Time{}
+	d.lock.Unlock()
+}
+
+func (d *durable) stop() {
+	d.lock.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.lock.Unlock()
+}
@@ -0,0 +1,217 @@
+package bcast
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Create new broadcast group from a log and join a durable member.
+// Acknowledge a part of the messages, leave and join again.
+// Check that the member resumes after the acknowledged messages.
+func TestDurableResume(t *testing.T) {
+	group, err := NewGroupFromLog[int](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	worker, err := group.JoinDurable("worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := group.JoinDurable("worker"); err != ErrDurableActive {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		group.Send(i)
+	}
+	for i := 0; i < 5; i++ {
+		envelope := <-worker.Envelopes()
+		if envelope.Payload != i {
+			t.Fatalf("expected %d, got %d", i, envelope.Payload)
+		}
+		if i == 2 {
+			if err := worker.Ack(envelope.Clock); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	worker.Close()
+	group.Send(5)
+
+	worker, err = group.JoinDurable("worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.Send(6)
+	for i := 3; i <= 6; i++ {
+		if envelope := <-worker.Envelopes(); envelope.Payload != i {
+			t.Fatalf("expected %d, got %d", i, envelope.Payload)
+		}
+	}
+	group.Close()
+}
+
+// Create new broadcast group from a log and join a durable member.
+// Do not acknowledge a message in time.
+// Check that it is delivered again until acknowledged.
+func TestDurableRedelivery(t *testing.T) {
+	group, err := NewGroupFromLog[string](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	worker, err := group.JoinDurable("worker", WithAckTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.Send("once")
+	first := <-worker.Envelopes()
+	second := <-worker.Envelopes()
+	if second.Clock != first.Clock || second.Payload != "once" {
+		t.Fatalf("unexpected redelivery %+v", second)
+	}
+	worker.Ack(second.Clock)
+	select {
+	case envelope := <-worker.Envelopes():
+		t.Fatalf("acknowledged message delivered again: %+v", envelope)
+	case <-time.After(60 * time.Millisecond):
+	}
+	group.Close()
+}
+
+// Create new broadcast group from a log and join a durable member.
+// Send a message which expires before the ack timeout.
+// Check that it is not delivered again.
+func TestDurableExpired(t *testing.T) {
+	group, err := NewGroupFromLog[string](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	worker, err := group.JoinDurable("worker", WithAckTimeout(40*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.Send("stale", WithTTL(20*time.Millisecond))
+	if envelope := <-worker.Envelopes(); envelope.Payload != "stale" {
+		t.Fatalf("unexpected delivery %+v", envelope)
+	}
+	select {
+	case envelope := <-worker.Envelopes():
+		t.Fatalf("expired message delivered again: %+v", envelope)
+	case <-time.After(120 * time.Millisecond):
+	}
+	if stats := group.Stats(); stats.Expired != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	group.Close()
+}
+
+// Create new broadcast group from a log and join a durable member.
+// Send a message with TTL, receive it without acknowledging and
+// restart the group after the message expires.
+// Check that the resumed member skips the expired message.
+func TestDurableExpiredResume(t *testing.T) {
+	dir := t.TempDir()
+	group, err := NewGroupFromLog[string](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	worker, err := group.JoinDurable("worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.Send("stale", WithTTL(20*time.Millisecond))
+	group.Send("fresh")
+	for _, expected := range []string{"stale", "fresh"} {
+		if envelope := <-worker.Envelopes(); envelope.Payload != expected {
+			t.Fatalf("expected %s, got %+v", expected, envelope)
+		}
+	}
+	group.Close()
+	time.Sleep(40 * time.Millisecond)
+
+	group, err = NewGroupFromLog[string](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	if worker, err = group.JoinDurable("worker"); err != nil {
+		t.Fatal(err)
+	}
+	if envelope := <-worker.Envelopes(); envelope.Payload != "fresh" || !envelope.Deadline.IsZero() {
+		t.Fatalf("unexpected delivery %+v", envelope)
+	}
+	if stats := group.Stats(); stats.Expired != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	group.Close()
+}
+
+// Create new broadcast group from a log and join two durable members.
+// Send messages to each of them and leave without acknowledging.
+// Check that each member resumes with its own targeted message.
+func TestDurableTargeted(t *testing.T) {
+	dir := t.TempDir()
+	group, err := NewGroupFromLog[string](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	sender := group.Join()
+	first, err := group.JoinDurable("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := group.JoinDurable("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.SendTo([]MemberID{first.ID()}, "to first")
+	group.SendWhere(func(m *TypedMember[string]) bool { return m == second }, "to second")
+	sender.Send("to all")
+	for i := 0; i < 2; i++ {
+		<-first.Envelopes()
+		<-second.Envelopes()
+	}
+	group.Close()
+
+	group, err = NewGroupFromLog[string](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go group.Broadcast(0)
+	for name, expected := range map[string]string{"first": "to first", "second": "to second"} {
+		worker, err := group.JoinDurable(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, payload := range []string{expected, "to all"} {
+			if envelope := <-worker.Envelopes(); envelope.Payload != payload {
+				t.Fatalf("%s: expected %s, got %+v", name, payload, envelope)
+			}
+		}
+	}
+	group.Close()
+}
+
+// Join durable members with wrong names or to a group without log.
+// Check the errors.
+func TestDurableErrors(t *testing.T) {
+	if _, err := NewGroup().JoinDurable("worker"); err != ErrNoLog {
+		t.Fatalf("unexpected error %v", err)
+	}
+	group, err := NewGroupFromLog[int](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := group.JoinDurable("../worker"); err != ErrInvalidName {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := group.Join().Ack(0); !errors.Is(err, ErrNotDurable) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	group.Close()
+}
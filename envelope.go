@@ -0,0 +1,65 @@
+package bcast
+
+import (
+	"context"
+	"time"
+)
+
+// Envelope carries a delivered payload together with the information
+// about the message. Members joined with WithEnvelopes option receive
+// envelopes from the channel returned by Member.Envelopes instead of
+// the bare payloads from Read.
+type Envelope[T any] struct {
+	Sender MemberID // zero for the messages sent by the group itself
+	Clock  int64    // position of the message in the group sequence
+	Time   time.Time
+	// Deadline is the time the message expires at, it is zero if
+	// the message never expires.
+	Deadline time.Time
+	Topic    string
+	Headers  map[string]string // shared by all the receivers, must not be modified
+	Payload  T
+	// Context carries the deliver span of the message if the group
+	// has a tracer, it is nil otherwise.
+	Context context.Context
+}
+
+// Envelopes returns the channel of the envelopes delivered to the
+// member. It is nil unless the member joined with WithEnvelopes
+// option. The channel is closed when the member leaves the group.
+func (m *TypedMember[T]) Envelopes() <-chan Envelope[T] {
+	return m.envelopes
+}
+
+// newMessage packs the value sent by the member or by the group when
+// the sender is nil.
+func newMessage[T any](sender *TypedMember[T], val T, opts []SendOption) Message[T] {
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Message[T]{
+		sender:   sender,
+		payload:  val,
+		sent:     time.Now(),
+		headers:  o.headers,
+		trace:    o.trace,
+		deadline: o.deadline,
+	}
+}
+
+func (message *Message[T]) envelope() Envelope[T] {
+	sender := message.from
+	if message.sender != nil {
+		sender = message.sender.id
+	}
+	return Envelope[T]{
+		Sender:   sender,
+		Clock:    message.clock,
+		Time:     message.sent,
+		Deadline: message.deadline,
+		Topic:    message.topic,
+		Headers:  message.headers,
+		Payload:  message.payload,
+	}
+}
@@ -0,0 +1,46 @@
+package bcast
+
+import (
+	"testing"
+	"time"
+)
+
+// Create new broadcast group.
+// Join member receiving envelopes.
+// Check sender, clock, time, topic and headers of the delivered messages.
+func TestEnvelopes(t *testing.T) {
+	group := NewGroupOf[string]()
+	sender := group.Join()
+	receiver := group.JoinWithOptions(WithEnvelopes(), WithTopics("news"))
+	if sender.ID() == 0 || sender.ID() == receiver.ID() {
+		t.Fatal("members must have distinct non-zero IDs")
+	}
+	go group.Broadcast(0)
+	before := time.Now()
+	group.Send("from group")
+	sender.Send("from member", WithHeader("trace", "42"))
+	sender.Publish("news", "on topic")
+
+	envelope := <-receiver.Envelopes()
+	if envelope.Sender != 0 || envelope.Payload != "from group" || envelope.Clock != 0 {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+	if envelope.Time.Before(before) {
+		t.Fatal("envelope must carry send time")
+	}
+	envelope = <-receiver.Envelopes()
+	if envelope.Sender != sender.ID() || envelope.Payload != "from member" || envelope.Clock != 1 {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+	if envelope.Headers["trace"] != "42" {
+		t.Fatalf("expected header, got %v", envelope.Headers)
+	}
+	envelope = <-receiver.Envelopes()
+	if envelope.Topic != "news" || envelope.Clock != 2 {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+	group.Close()
+	if _, ok := <-receiver.Envelopes(); ok {
+		t.Fatal("Envelopes channel must be closed")
+	}
+}
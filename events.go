@@ -0,0 +1,171 @@
+package bcast
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EventKind tells what happened to the membership of a group.
+type EventKind int
+
+const (
+	// Joined is emitted when a member joins the group.
+	Joined EventKind = iota
+	// Left is emitted when a member leaves the group.
+	Left
+	// Evicted is emitted when the dispatcher removes a member which
+	// could not keep up with the messages.
+	Evicted
+	// GroupClosed is emitted when the group is closed. It is the
+	// last event of the group.
+	GroupClosed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Joined:
+		return "joined"
+	case Left:
+		return "left"
+	case Evicted:
+		return "evicted"
+	case GroupClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// MembershipEvent describes a change of the membership of a group.
+type MembershipEvent struct {
+	Kind EventKind
+	// Member is the ID of the member which joined or left the group,
+	// it is zero for GroupClosed.
+	Member MemberID
+	// Clock is the group clock at the moment of the change. A joined
+	// member receives the messages starting from this clock.
+	Clock int64
+}
+
+func (e MembershipEvent) String() string {
+	if e.Kind == GroupClosed {
+		return fmt.Sprintf("group %s at %d", e.Kind, e.Clock)
+	}
+	return fmt.Sprintf("member %d %s at %d", e.Member, e.Kind, e.Clock)
+}
+
+// EventMessages makes the group broadcast its membership events to
+// the members as the ordinary messages, so the members learn about
+// the changes in order with the rest of the stream. The message of an
+// event takes the clock of the event, a joined member receives its own
+// Joined event first. The messages sent before a member joins or
+// leaves come ahead of its event. The convert function turns an event
+// into the payload, it is called under the locks of the group, so it
+// must not call the group. Nil function stops sending the events.
+func (g *TypedGroup[T]) EventMessages(convert func(event MembershipEvent) T) {
+	g.memberLock.Lock()
+	g.clockLock.Lock()
+	g.eventConvert = convert
+	g.clockLock.Unlock()
+	g.memberLock.Unlock()
+}
+
+// Events returns the channel of the membership events of the group.
+// The events which happen after the first call are emitted in order
+// and never block the group, they are queued until read. The channel
+// is closed after the GroupClosed event.
+func (g *TypedGroup[T]) Events() <-chan MembershipEvent {
+	g.memberLock.Lock()
+	defer g.memberLock.Unlock()
+	if g.events == nil {
+		events := make(chan MembershipEvent)
+		g.events = events
+		g.eventPump = newPump(func(event MembershipEvent) {
+			events <- event
+		}, func() {
+			close(events)
+		})
+		if g.closed {
+			g.eventPump.close()
+		}
+	}
+	return g.events
+}
+
+// emit queues the membership event. It is called under the member
+// and clock locks, so the events are queued in the order of the
+// changes and their messages are stamped with the clocks of the
+// events. The caller publishes the messages and notifies the
+// observers after it releases the locks.
+func (g *TypedGroup[T]) emit(kind EventKind, member MemberID, clock int64) {
+	event := MembershipEvent{Kind: kind, Member: member, Clock: clock}
+	g.observeMembership(event)
+	if g.eventPump != nil {
+		g.eventPump.push(event)
+		if kind == GroupClosed {
+			g.eventPump.close()
+		}
+	}
+	if g.eventConvert != nil && kind != GroupClosed && !g.stopped {
+		message := newMessage(nil, g.eventConvert(event), nil)
+		g.stamp(&message)
+	}
+}
+
+// pump passes the values to the consumer in order without blocking
+// the producer.
+type pump[E any] struct {
+	lock   sync.Mutex
+	queue  []E
+	wake   chan struct{}
+	closed bool
+}
+
+// newPump starts the goroutine which passes the pushed values to
+// consume and calls done after the pump is closed and drained.
+func newPump[E any](consume func(E), done func()) *pump[E] {
+	p := &pump[E]{wake: make(chan struct{}, 1)}
+	go p.run(consume, done)
+	return p
+}
+
+func (p *pump[E]) push(value E) {
+	p.lock.Lock()
+	if !p.closed {
+		p.queue = append(p.queue, value)
+	}
+	p.lock.Unlock()
+	p.signal()
+}
+
+func (p *pump[E]) close() {
+	p.lock.Lock()
+	p.closed = true
+	p.lock.Unlock()
+	p.signal()
+}
+
+func (p *pump[E]) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *pump[E]) run(consume func(E), done func()) {
+	for {
+		p.lock.Lock()
+		queue, closed := p.queue, p.closed
+		p.queue = nil
+		p.lock.Unlock()
+		for _, value := range queue {
+			consume(value)
+		}
+		if len(queue) == 0 {
+			if closed {
+				done()
+				return
+			}
+			<-p.wake
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package bcast
+
+import (
+	"testing"
+)
+
+// Create new broadcast group and subscribe to its events.
+// Join, leave and evict members, close the group.
+// Check that the events come in order with the right clocks.
+func TestEvents(t *testing.T) {
+	group := NewGroupOf[int]()
+	events := group.Events()
+	go group.Broadcast(0)
+
+	first := group.Join()
+	group.Send(1)
+	first.Recv()
+	slow := group.JoinWithOptions(WithBufferSize(1), WithOverflowPolicy(Evict))
+	group.Send(2)
+	// The listener of the slow member holds the message, the next
+	// one fills the buffer and the last one overflows it.
+	waitStats(t, group, func(stats GroupStats) bool {
+		for _, member := range stats.Members {
+			if member.ID == slow.ID() {
+				return member.In == 1
+			}
+		}
+		return false
+	})
+	group.Send(3)
+	group.Send(4)
+	for i := 0; i < 3; i++ {
+		first.Recv()
+	}
+	first.Close()
+	group.Close()
+
+	expected := []MembershipEvent{
+		{Kind: Joined, Member: first.ID(), Clock: 0},
+		{Kind: Joined, Member: slow.ID(), Clock: 1},
+		{Kind: Evicted, Member: slow.ID(), Clock: 4},
+		{Kind: Left, Member: first.ID(), Clock: 4},
+		{Kind: GroupClosed, Clock: 4},
+	}
+	for _, e := range expected {
+		if event := <-events; event != e {
+			t.Fatalf("expected %v, got %v", e, event)
+		}
+	}
+	if event, ok := <-events; ok {
+		t.Fatalf("unexpected event %v", event)
+	}
+}
+
+// Create new broadcast group which sends its events as messages.
+// Join and leave a member between the messages.
+// Check that the first member sees the changes in the stream in order.
+func TestEventMessages(t *testing.T) {
+	group := NewGroup()
+	group.EventMessages(func(event MembershipEvent) any {
+		return event
+	})
+	first := group.Join()
+	go group.Broadcast(0)
+
+	group.Send("before")
+	second := group.Join()
+	second.Close()
+	group.Send("after")
+
+	expected := []any{
+		MembershipEvent{Kind: Joined, Member: first.ID(), Clock: 0},
+		"before",
+		MembershipEvent{Kind: Joined, Member: second.ID(), Clock: 2},
+		MembershipEvent{Kind: Left, Member: second.ID(), Clock: 3},
+		"after",
+	}
+	for _, e := range expected {
+		if val := first.Recv(); val != e {
+			t.Fatalf("expected %v, got %v", e, val)
+		}
+	}
+	group.Close()
+}
@@ -1,3 +1,5 @@
 module github.com/grafov/bcast
 
+go 1.23
+
 // go: no requirements found in vendor/vendor.json
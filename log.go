@@ -0,0 +1,574 @@
+package bcast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize is the size after which the log of a group
+// starts a new segment.
+const DefaultSegmentSize = 64 << 20
+
+// ErrCorruptedLog is returned when a record in the middle of the log
+// can not be read.
+var ErrCorruptedLog = errors.New("Log is corrupted")
+
+const (
+	segmentSuffix    = ".log"
+	recordHeaderSize = 4 + 4 // length and checksum of the record
+	maxRecordSize    = 1 << 30
+	// markerVersion starts the records of the messages which are not
+	// replayed, such as requests and the messages sent to the chosen
+	// members which are not durable. They keep only the clock, so the
+	// clock of the group restored from the log never goes back.
+	markerVersion = 0
+	// targetedVersion starts the records of the messages sent to the
+	// chosen members. They keep the names of the durable receivers
+	// before the envelope and are replayed to these members only.
+	targetedVersion = 255
+)
+
+// Log is an append-only log of the messages broadcast by a group. It
+// is kept in a directory as the segments named by the clock of their
+// first message.
+type Log struct {
+	dir      string
+	opts     groupOptions
+	lock     sync.Mutex
+	written  *sync.Cond // signalled when next grows or the log closes
+	segments []int64    // first clocks of the segments in order
+	file     *os.File
+	size     int64 // size of the last segment
+	next     int64 // clock after the last record
+	closed   bool
+	syncing  *time.Timer // commits the records written since the last sync
+	err      error
+}
+
+// WithCodec sets the codec which encodes the payloads for the log.
+// GobCodec is used by default.
+func WithCodec(codec Codec) GroupOption {
+	return func(o *groupOptions) {
+		o.codec = codec
+	}
+}
+
+// WithSegmentSize sets the size in bytes after which the log starts
+// a new segment. DefaultSegmentSize is used by default.
+func WithSegmentSize(size int64) GroupOption {
+	return func(o *groupOptions) {
+		o.segmentSize = size
+	}
+}
+
+// WithLogRetention makes the log remove its oldest segments when the
+// log grows over maxSize bytes or when the segments were not written
+// for maxAge. Zero disables the limit. The segment being written is
+// never removed.
+func WithLogRetention(maxSize int64, maxAge time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.logMaxSize = maxSize
+		o.logMaxAge = maxAge
+	}
+}
+
+// WithLogSync makes the log commit the records to the stable storage
+// no later than the interval after they are written, so the messages
+// survive a crash of the machine and not only of the process. Zero
+// interval commits every record before its message is published, at
+// the cost of the throughput. Without the option the log leaves it to
+// the operating system, Log.Sync commits the records on demand.
+func WithLogSync(interval time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.logSync = true
+		o.logSyncInterval = interval
+	}
+}
+
+// WithReplayFrom makes the member of a group created by
+// NewGroupFromLog receive the logged messages starting from the clock
+// before the messages sent after it joined. Messages removed by the
+// log retention are skipped.
+func WithReplayFrom(clock int64) MemberOption {
+	return func(o *memberOptions) {
+		o.replayFrom = clock
+		o.replay = true
+	}
+}
+
+// NewGroupFromLog creates a new broadcast group which records every
+// broadcast message to the log in the directory before publishing it
+// to the members. The directory is created if it does not exist. If
+// the log holds messages already, the group clock continues after the
+// last of them, so the members may replay the messages of the
+// previous runs with WithReplayFrom option. The records are written by
+// the dispatcher without holding the group locks, see WithLogSync for
+// their durability.
+func NewGroupFromLog[T any](path string, opts ...GroupOption) (*TypedGroup[T], error) {
+	g := NewGroupOf[T](opts...)
+	if g.opts.codec == nil {
+		g.opts.codec = GobCodec{}
+	}
+	if g.opts.segmentSize <= 0 {
+		g.opts.segmentSize = DefaultSegmentSize
+	}
+	log, err := openLog(path, g.opts)
+	if err != nil {
+		return nil, err
+	}
+	g.log = log
+	g.clock = log.next
+	g.head.Store(log.next)
+	return g, nil
+}
+
+// Log returns the log of the group or nil if the group was not created
+// by NewGroupFromLog.
+func (g *TypedGroup[T]) Log() *Log {
+	return g.log
+}
+
+// logMessage writes the message to the log before it is published to
+// the members. It is called under the dispatch lock, so the records
+// are written in the order of the clocks. The messages sent to the
+// chosen members are written with the names of the durable receivers
+// among the members, the requests and the messages without durable
+// receivers are written as the markers of their clocks.
+func (g *TypedGroup[T]) logMessage(message *Message[T], members []*TypedMember[T]) {
+	var names []string
+	if message.call == nil && message.where != nil {
+		for _, member := range members {
+			if d := member.opts.durable; d != nil && message.where(member) {
+				names = append(names, d.name)
+			}
+		}
+	}
+	var data []byte
+	if message.call == nil && (message.where == nil || len(names) > 0) {
+		var err error
+		if data, err = MarshalEnvelope(g.opts.codec, message.envelope()); err != nil {
+			// The clock is kept even if the payload is lost.
+			g.log.fail(err)
+			data = nil
+		}
+	}
+	if data != nil && len(names) > 0 {
+		data = appendTargeted(message.clock, names, data)
+	}
+	if data == nil {
+		data = binary.AppendVarint([]byte{markerVersion}, message.clock)
+	}
+	if err := g.log.append(message.clock, data); err != nil {
+		g.log.fail(err)
+	}
+}
+
+// replayLog reads the logged messages from the clock up to the clock
+// the member joined at. The messages sent to the chosen members are
+// read only for the durable member with the name among their
+// receivers. It waits until the messages stamped before the member
+// joined are written.
+func (g *TypedGroup[T]) replayLog(from, until int64, durable string) []*Message[T] {
+	g.log.wait(until)
+	var messages []*Message[T]
+	err := g.log.read(from, until, func(data []byte) error {
+		if data[0] == targetedVersion {
+			names, envelope, err := splitTargeted(data)
+			if err != nil {
+				return err
+			}
+			if durable == "" || !slices.Contains(names, durable) {
+				return nil
+			}
+			data = envelope
+		}
+		envelope, err := UnmarshalEnvelope[T](g.opts.codec, data)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, &Message[T]{
+			from:     envelope.Sender,
+			payload:  envelope.Payload,
+			clock:    envelope.Clock,
+			topic:    envelope.Topic,
+			sent:     envelope.Time,
+			headers:  envelope.Headers,
+			deadline: envelope.Deadline,
+		})
+		return nil
+	})
+	if err != nil {
+		g.log.fail(err)
+	}
+	return messages
+}
+
+func openLog(dir string, opts groupOptions) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir, opts: opts}
+	l.written = sync.NewCond(&l.lock)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		first, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		l.segments = append(l.segments, first)
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i] < l.segments[j] })
+	if len(l.segments) == 0 {
+		return l, nil
+	}
+	last := l.segments[len(l.segments)-1]
+	l.next = last
+	// A crash may leave a torn record at the end, the log continues
+	// after the last complete one.
+	size, err := scanSegment(l.path(last), func(data []byte) error {
+		clock, _, err := recordClock(data)
+		if err != nil {
+			return err
+		}
+		l.next = clock + 1
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrCorruptedLog) {
+		return nil, err
+	}
+	l.file, err = os.OpenFile(l.path(last), os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.file.Truncate(size); err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	if _, err := l.file.Seek(size, io.SeekStart); err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	l.size = size
+	return l, nil
+}
+
+// Err returns the first error the group met writing or reading the
+// log. Messages are still broadcast after the error but they may be
+// missing in the log.
+func (l *Log) Err() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.err
+}
+
+// Sync commits the log to the stable storage.
+func (l *Log) Sync() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
+// wait blocks until the records below the clock are written or the
+// log is closed.
+func (l *Log) wait(clock int64) {
+	l.lock.Lock()
+	for l.next < clock && !l.closed {
+		l.written.Wait()
+	}
+	l.lock.Unlock()
+}
+
+// syncLater commits the records written during the sync interval. It
+// is called by the timer.
+func (l *Log) syncLater() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.syncing = nil
+	if l.file == nil {
+		return
+	}
+	if err := l.file.Sync(); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+func (l *Log) fail(err error) {
+	l.lock.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.lock.Unlock()
+}
+
+func (l *Log) close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closed = true
+	l.written.Broadcast()
+	if l.syncing != nil {
+		l.syncing.Stop()
+		l.syncing = nil
+	}
+	if l.file == nil {
+		return nil
+	}
+	var err error
+	if l.opts.logSync {
+		err = l.file.Sync()
+	}
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+func (l *Log) path(first int64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", first, segmentSuffix))
+}
+
+// append writes the record of the message with the clock. The clock
+// counts as written even if the write fails, the error is kept by the
+// log then.
+func (l *Log) append(clock int64, data []byte) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	defer l.written.Broadcast()
+	l.next = clock + 1
+	if l.closed {
+		return nil
+	}
+	if l.file == nil || l.size >= l.opts.segmentSize {
+		if err := l.rotate(clock); err != nil {
+			return err
+		}
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(data))
+	record = append(record, data...)
+	if _, err := l.file.Write(record); err != nil {
+		return err
+	}
+	l.size += int64(len(record))
+	if !l.opts.logSync {
+		return nil
+	}
+	if l.opts.logSyncInterval <= 0 {
+		return l.file.Sync()
+	}
+	if l.syncing == nil {
+		l.syncing = time.AfterFunc(l.opts.logSyncInterval, l.syncLater)
+	}
+	return nil
+}
+
+// rotate starts a new segment with the clock and removes the old
+// segments according to the retention.
+func (l *Log) rotate(clock int64) error {
+	if l.file != nil {
+		if l.opts.logSync {
+			if err := l.file.Sync(); err != nil {
+				return err
+			}
+		}
+		if err := l.file.Close(); err != nil {
+			return err
+		}
+		l.file = nil
+	}
+	file, err := os.OpenFile(l.path(clock), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	l.file = file
+	l.size = 0
+	l.segments = append(l.segments, clock)
+	return l.retain()
+}
+
+func (l *Log) retain() error {
+	if l.opts.logMaxSize <= 0 && l.opts.logMaxAge <= 0 {
+		return nil
+	}
+	var total int64
+	infos := make([]os.FileInfo, len(l.segments))
+	for i, first := range l.segments {
+		info, err := os.Stat(l.path(first))
+		if err != nil {
+			return err
+		}
+		infos[i] = info
+		total += info.Size()
+	}
+	removed := 0
+	for i := 0; i < len(l.segments)-1; i++ {
+		tooBig := l.opts.logMaxSize > 0 && total > l.opts.logMaxSize
+		tooOld := l.opts.logMaxAge > 0 && time.Since(infos[i].ModTime()) > l.opts.logMaxAge
+		if !tooBig && !tooOld {
+			break
+		}
+		if err := os.Remove(l.path(l.segments[i])); err != nil {
+			return err
+		}
+		total -= infos[i].Size()
+		removed++
+	}
+	l.segments = l.segments[removed:]
+	return nil
+}
+
+// read passes the records with the clocks from the first up to the
+// until to fn in order.
+func (l *Log) read(from, until int64, fn func(data []byte) error) error {
+	l.lock.Lock()
+	segments := append([]int64(nil), l.segments...)
+	l.lock.Unlock()
+	start := sort.Search(len(segments), func(i int) bool { return segments[i] > from }) - 1
+	if start < 0 {
+		start = 0
+	}
+	errDone := errors.New("done")
+	for i, first := range segments[start:] {
+		if first >= until {
+			break
+		}
+		last := start+i == len(segments)-1
+		_, err := scanSegment(l.path(first), func(data []byte) error {
+			clock, marker, err := recordClock(data)
+			if err != nil {
+				return err
+			}
+			if clock >= until {
+				return errDone
+			}
+			if clock < from || marker {
+				return nil
+			}
+			return fn(data)
+		})
+		switch {
+		case errors.Is(err, errDone):
+			return nil
+		case errors.Is(err, os.ErrNotExist):
+			// Removed by the retention meanwhile.
+		case errors.Is(err, ErrCorruptedLog) && last:
+			// The record being written now.
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+// scanSegment passes the records of the segment to fn and returns the
+// size of the complete records. Reading stops with ErrCorruptedLog at
+// a torn or damaged record.
+func scanSegment(path string, fn func(data []byte) error) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	var size int64
+	var header [recordHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return size, nil
+			}
+			return size, ErrCorruptedLog
+		}
+		length := binary.BigEndian.Uint32(header[:])
+		if length > maxRecordSize {
+			return size, ErrCorruptedLog
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return size, ErrCorruptedLog
+		}
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+			return size, ErrCorruptedLog
+		}
+		if err := fn(data); err != nil {
+			return size, err
+		}
+		size += int64(recordHeaderSize + len(data))
+	}
+}
+
+// recordClock reads the clock of the envelope encoded by
+// MarshalEnvelope, of the targeted message or of the marker without
+// decoding the rest of the record.
+func recordClock(data []byte) (clock int64, marker bool, err error) {
+	if len(data) == 0 {
+		return 0, false, ErrMalformedEnvelope
+	}
+	switch data[0] {
+	case envelopeVersion, envelopeVersionNoDeadline, targetedVersion, markerVersion:
+	default:
+		return 0, false, ErrMalformedEnvelope
+	}
+	clock, n := binary.Varint(data[1:])
+	if n <= 0 {
+		return 0, false, ErrMalformedEnvelope
+	}
+	return clock, data[0] == markerVersion, nil
+}
+
+// appendTargeted makes the record of the message sent to the chosen
+// members from the names of its durable receivers and its envelope.
+// The clock follows the version as in the other records.
+func appendTargeted(clock int64, names []string, envelope []byte) []byte {
+	buf := binary.AppendVarint([]byte{targetedVersion}, clock)
+	buf = binary.AppendUvarint(buf, uint64(len(names)))
+	for _, name := range names {
+		buf = appendString(buf, name)
+	}
+	return append(buf, envelope...)
+}
+
+// splitTargeted returns the names of the durable receivers and the
+// envelope of the record made by appendTargeted.
+func splitTargeted(data []byte) (names []string, envelope []byte, err error) {
+	r := bytes.NewReader(data[1:])
+	if _, err := binary.ReadVarint(r); err != nil {
+		return nil, nil, ErrMalformedEnvelope
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(r.Len()) {
+		return nil, nil, ErrMalformedEnvelope
+	}
+	for i := uint64(0); i < count; i++ {
+		name, err := readString(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+	}
+	return names, data[len(data)-r.Len():], nil
+}
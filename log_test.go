@@ -0,0 +1,208 @@
+package bcast
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// logGroup creates a group from the log and sends the values through
+// it, the group is closed after a member receives them all.
+func logGroup(t *testing.T, dir string, values []int, opts ...GroupOption) {
+	group, err := NewGroupFromLog[int](dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := group.Join()
+	go group.Broadcast(0)
+	for _, val := range values {
+		group.Send(val, WithHeader("n", "x"))
+	}
+	for range values {
+		member.Recv()
+	}
+	group.Close()
+	if err := group.Log().Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Create new broadcast group from an empty log and send messages.
+// Restart the group from the same log.
+// Check that the clock continues and the messages are replayed.
+func TestLogRestart(t *testing.T) {
+	dir := t.TempDir()
+	logGroup(t, dir, []int{0, 1, 2, 3, 4})
+
+	group, err := NewGroupFromLog[int](dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := group.JoinWithOptions(WithReplayFrom(2), WithEnvelopes())
+	go group.Broadcast(0)
+	group.Send(5)
+	for i := 2; i <= 5; i++ {
+		envelope := <-member.Envelopes()
+		if envelope.Payload != i || envelope.Clock != int64(i) {
+			t.Fatalf("expected %d, got %+v", i, envelope)
+		}
+		if i < 5 && envelope.Headers["n"] != "x" {
+			t.Fatalf("headers are lost: %+v", envelope)
+		}
+	}
+	group.Close()
+}
+
+// Create new broadcast group from a log with small segments.
+// Send enough messages to rotate the segments many times.
+// Check that the retention removes old segments and the rest replays.
+func TestLogRetention(t *testing.T) {
+	dir := t.TempDir()
+	var values []int
+	for i := 0; i < 100; i++ {
+		values = append(values, i)
+	}
+	logGroup(t, dir, values, WithSegmentSize(200), WithLogRetention(1000, 0))
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	if len(segments) < 2 {
+		t.Fatalf("segments are not rotated: %v", segments)
+	}
+	var total int64
+	for _, segment := range segments {
+		info, _ := os.Stat(segment)
+		total += info.Size()
+	}
+	if total > 1000+200 {
+		t.Fatalf("log is not limited: %d bytes", total)
+	}
+
+	// The replayed messages are delivered before Read is closed.
+	group, err := NewGroupFromLog[int](dir, WithClosePolicy(DrainOnClose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := group.JoinWithOptions(WithReplayFrom(0))
+	group.Close()
+	var replayed []int
+	for val := range member.Read {
+		replayed = append(replayed, val)
+	}
+	if len(replayed) == 0 || replayed[0] == 0 || replayed[len(replayed)-1] != 99 {
+		t.Fatalf("unexpected replay %v", replayed)
+	}
+	for i := 1; i < len(replayed); i++ {
+		if replayed[i] != replayed[i-1]+1 {
+			t.Fatalf("gap in replay %v", replayed)
+		}
+	}
+}
+
+// Create new broadcast group from a log with a torn record at the end.
+// Check that the group continues after the last complete record.
+func TestLogTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	logGroup(t, dir, []int{0, 1, 2}, WithLogSync(time.Millisecond))
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	file, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 1, 0, 42})
+	file.Close()
+
+	logGroup(t, dir, []int{3})
+	// The replayed messages are delivered before Read is closed.
+	group, err := NewGroupFromLog[int](dir, WithClosePolicy(DrainOnClose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := group.JoinWithOptions(WithReplayFrom(0))
+	group.Close()
+	expected := 0
+	for val := range member.Read {
+		if val != expected {
+			t.Fatalf("expected %d, got %d", expected, val)
+		}
+		expected++
+	}
+	if expected != 4 {
+		t.Fatalf("replayed %d messages", expected)
+	}
+}
+
+// Create new broadcast group with a tracer from a log with messages.
+// Join a member replaying the log.
+// Check that the replayed messages are traced as new traces.
+func TestLogReplayTracing(t *testing.T) {
+	dir := t.TempDir()
+	logGroup(t, dir, []int{0, 1})
+
+	tracer := NewMemoryTracer()
+	group, err := NewGroupFromLog[int](dir, WithTracer(tracer), WithClosePolicy(DrainOnClose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member := group.JoinWithOptions(WithReplayFrom(0))
+	for i := 0; i < 2; i++ {
+		if val := member.Recv(); val != i {
+			t.Fatalf("expected %d, got %d", i, val)
+		}
+	}
+	group.Close()
+	for range member.Read {
+	}
+	var delivered int
+	for _, span := range tracer.Spans() {
+		if span.Name == SpanDeliver {
+			if span.Parent != 0 {
+				t.Fatalf("replayed message has parent span %+v", span)
+			}
+			delivered++
+		}
+	}
+	if delivered != 2 {
+		t.Fatalf("expected 2 deliver spans, got %d", delivered)
+	}
+}
+
+// Create new broadcast group from a log which syncs every record.
+// Send a message and messages to the chosen members only.
+// Restart the group and check that the clock is not reused.
+func TestLogClockMarkers(t *testing.T) {
+	dir := t.TempDir()
+	group, err := NewGroupFromLog[int](dir, WithLogSync(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := group.Join()
+	member := group.Join()
+	go group.Broadcast(0)
+	sender.Send(0)
+	sender.SendTo([]MemberID{member.ID()}, 1)
+	group.SendWhere(func(*TypedMember[int]) bool { return true }, 2)
+	for i := 0; i < 3; i++ {
+		member.Recv()
+	}
+	group.Close()
+	if err := group.Log().Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	group, err = NewGroupFromLog[int](dir, WithClosePolicy(DrainOnClose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member = group.JoinWithOptions(WithReplayFrom(0), WithEnvelopes())
+	go group.Broadcast(0)
+	group.Send(3)
+	// The targeted messages are not replayed.
+	for _, expected := range []Envelope[int]{{Clock: 0, Payload: 0}, {Clock: 3, Payload: 3}} {
+		envelope := <-member.Envelopes()
+		if envelope.Clock != expected.Clock || envelope.Payload != expected.Payload {
+			t.Fatalf("expected %+v, got %+v", expected, envelope)
+		}
+	}
+	group.Close()
+}
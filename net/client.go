@@ -0,0 +1,181 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/grafov/bcast"
+)
+
+// Member is a remote member of a group served by a Server. It
+// receives the messages of the group in the order of the group clock,
+// like the local members do.
+type Member[T any] struct {
+	Read      <-chan T
+	read      chan T
+	envelopes chan bcast.Envelope[T]
+	id        bcast.MemberID
+	conn      net.Conn
+	codec     bcast.Codec
+	lock      sync.Mutex // serializes writes
+	w         *bufio.Writer
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// DialOption configures a Member created by Dial.
+type DialOption func(*dialOptions)
+
+type dialOptions struct {
+	envelopes bool
+}
+
+// WithEnvelopes makes the member receive envelopes from the channel
+// returned by Member.Envelopes instead of the bare payloads from
+// Read. Envelopes carry the clock, the sender, the send time, the
+// topic and the headers of the messages.
+func WithEnvelopes() DialOption {
+	return func(o *dialOptions) {
+		o.envelopes = true
+	}
+}
+
+// Dial connects to the server listening on the TCP address and joins
+// its group. The codec must match the codec of the server.
+func Dial[T any](addr string, codec bcast.Codec, opts ...DialOption) (*Member[T], error) {
+	var o dialOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	hello, err := readFrame(r)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	id, n := binary.Uvarint(hello.body)
+	if hello.kind != frameHello || n <= 0 {
+		conn.Close()
+		return nil, ErrProtocol
+	}
+	m := &Member[T]{
+		id:    bcast.MemberID(id),
+		conn:  conn,
+		codec: codec,
+		w:     bufio.NewWriter(conn),
+		quit:  make(chan struct{}),
+	}
+	if o.envelopes {
+		m.envelopes = make(chan bcast.Envelope[T])
+	} else {
+		m.read = make(chan T)
+		m.Read = m.read
+	}
+	go m.listen(r)
+	return m, nil
+}
+
+// ID returns the ID of the member serving the connection on the
+// server side.
+func (m *Member[T]) ID() bcast.MemberID {
+	return m.id
+}
+
+// Envelopes returns the channel of the envelopes delivered to the
+// member. It is nil unless the member was dialed with WithEnvelopes.
+func (m *Member[T]) Envelopes() <-chan bcast.Envelope[T] {
+	return m.envelopes
+}
+
+// Send broadcasts a message to the other members of the group. The
+// message is stamped by the group on the server side, so the order of
+// the messages sent by one member is kept.
+func (m *Member[T]) Send(val T) error {
+	body, err := m.codec.Marshal(&val)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	select {
+	case <-m.quit:
+		return bcast.ErrMemberClosed
+	default:
+	}
+	return writeFrame(m.w, frame{kind: frameSend, body: body})
+}
+
+// Recv reads one value from the member's Read channel.
+func (m *Member[T]) Recv() T {
+	return <-m.Read
+}
+
+// RecvContext reads one value from the member's Read channel. It
+// returns bcast.ErrMemberClosed after the connection is closed and
+// ctx.Err() if ctx is done first.
+func (m *Member[T]) RecvContext(ctx context.Context) (T, error) {
+	select {
+	case val, ok := <-m.Read:
+		if !ok {
+			return val, bcast.ErrMemberClosed
+		}
+		return val, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Close disconnects the member, so it leaves the group, and closes
+// its Read channel.
+func (m *Member[T]) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		m.lock.Lock()
+		close(m.quit)
+		m.lock.Unlock()
+		err = m.conn.Close()
+	})
+	return err
+}
+
+// listen passes the messages from the connection to the member's
+// channel until the connection breaks.
+func (m *Member[T]) listen(r *bufio.Reader) {
+	if m.envelopes != nil {
+		defer close(m.envelopes)
+	} else {
+		defer close(m.read)
+	}
+	defer m.Close()
+	for {
+		f, err := readFrame(r)
+		if err != nil || f.kind != frameMessage {
+			return
+		}
+		envelope, err := bcast.UnmarshalEnvelope[T](m.codec, f.body)
+		if err != nil {
+			return
+		}
+		if m.envelopes != nil {
+			select {
+			case m.envelopes <- envelope:
+			case <-m.quit:
+				return
+			}
+			continue
+		}
+		select {
+		case m.read <- envelope.Payload:
+		case <-m.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package net bridges a bcast group over TCP. Server exposes a group
+// to the remote processes and Dial joins a group exposed by a server.
+// Every connection is served by a member of the group, so the remote
+// members receive the messages in the order of the group clock and do
+// not receive their own messages, the same as the local ones.
+// Payloads are encoded by a bcast.Codec, which must be the same on
+// both sides.
+package net
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrFrameTooLarge is returned when a peer sends a frame longer
+	// than MaxFrameSize.
+	ErrFrameTooLarge = errors.New("Frame is too large")
+	// ErrProtocol is returned when a peer sends an unexpected frame.
+	ErrProtocol = errors.New("Unexpected frame")
+)
+
+// MaxFrameSize limits the size of an encoded message.
+const MaxFrameSize = 16 << 20
+
+// Kinds of the frames.
+const (
+	frameHello   byte = iota + 1 // server tells the member ID to the client
+	frameMessage                 // server delivers an envelope to the client
+	frameSend                    // client sends a payload to the group
+)
+
+// frame is the unit of the protocol: the kind and the length of the
+// body followed by the body.
+type frame struct {
+	kind byte
+	body []byte
+}
+
+const headerSize = 1 + 4
+
+func writeFrame(w *bufio.Writer, f frame) error {
+	var header [headerSize]byte
+	header[0] = f.kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(f.body)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(f.body); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func readFrame(r *bufio.Reader) (frame, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > MaxFrameSize {
+		return frame{}, ErrFrameTooLarge
+	}
+	f := frame{kind: header[0], body: make([]byte, size)}
+	if _, err := io.ReadFull(r, f.body); err != nil {
+		return frame{}, err
+	}
+	return f, nil
+}
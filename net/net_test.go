@@ -0,0 +1,105 @@
+package net
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grafov/bcast"
+)
+
+func serve(t *testing.T, group *bcast.TypedGroup[string]) (*Server[string], string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(group, bcast.GobCodec{})
+	go server.Serve(l)
+	return server, l.Addr().String()
+}
+
+// Create new broadcast group and serve it over loopback.
+// Connect two remote members and join a local one.
+// Check that all the members see the same sequence.
+func TestRemoteMembers(t *testing.T) {
+	group := bcast.NewGroupOf[string]()
+	go group.Broadcast(0)
+	server, addr := serve(t, group)
+	defer server.Close()
+
+	first, err := Dial[string](addr, bcast.GobCodec{}, WithEnvelopes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Dial[string](addr, bcast.GobCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := group.Join()
+	for group.MemberCount() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	group.Send("from group")
+	second.Send("from second")
+	local.Send("from local")
+
+	var expected []string
+	clock := int64(-1)
+	for i := 0; i < 3; i++ {
+		envelope := <-first.Envelopes()
+		if envelope.Clock <= clock {
+			t.Fatalf("clock %d after %d", envelope.Clock, clock)
+		}
+		clock = envelope.Clock
+		if envelope.Payload == "from second" && envelope.Sender != second.ID() {
+			t.Fatalf("unexpected sender %d", envelope.Sender)
+		}
+		expected = append(expected, envelope.Payload)
+	}
+	// The remote and local members do not receive their own messages.
+	for _, e := range expected {
+		if e != "from second" {
+			if val := second.Recv(); val != e {
+				t.Fatalf("second: expected %q, got %q", e, val)
+			}
+		}
+		if e != "from local" {
+			if val := local.Recv(); val != e {
+				t.Fatalf("local: expected %q, got %q", e, val)
+			}
+		}
+	}
+
+	second.Close()
+	if _, ok := <-second.Read; ok {
+		t.Fatal("Read is not closed")
+	}
+	for group.MemberCount() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	first.Close()
+	group.Close()
+}
+
+// Create new broadcast group and serve it over loopback.
+// Close the server.
+// Check that the remote member is disconnected.
+func TestServerClose(t *testing.T) {
+	group := bcast.NewGroupOf[string]()
+	go group.Broadcast(0)
+	server, addr := serve(t, group)
+
+	member, err := Dial[string](addr, bcast.GobCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	if _, ok := <-member.Read; ok {
+		t.Fatal("Read is not closed")
+	}
+	if group.MemberCount() != 0 {
+		t.Fatalf("unexpected members %d", group.MemberCount())
+	}
+	group.Close()
+}
@@ -0,0 +1,148 @@
+package net
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/grafov/bcast"
+)
+
+// Server exposes a group to the clients connected over TCP. Each
+// connection joins the group as a member which forwards the messages
+// of the group to the client and sends the messages of the client to
+// the group.
+type Server[T any] struct {
+	group     *bcast.TypedGroup[T]
+	codec     bcast.Codec
+	opts      []bcast.MemberOption
+	lock      sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a server of the group. The members serving the
+// connections join the group with the options given.
+func NewServer[T any](group *bcast.TypedGroup[T], codec bcast.Codec, opts ...bcast.MemberOption) *Server[T] {
+	return &Server[T]{
+		group:     group,
+		codec:     codec,
+		opts:      append(opts[:len(opts):len(opts)], bcast.WithEnvelopes()),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves the
+// connections until the server is closed.
+func (s *Server[T]) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts the connections on the listener until the server is
+// closed. It returns nil when stopped by Close.
+func (s *Server[T]) Serve(l net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return nil
+	}
+	s.listeners[l] = struct{}{}
+	s.lock.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.lock.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// Close stops the listeners and disconnects the clients. The group
+// itself stays open.
+func (s *Server[T]) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serve joins the connection to the group and passes the messages
+// both ways until the connection breaks or the member leaves.
+func (s *Server[T]) serve(conn net.Conn) {
+	defer s.wg.Done()
+	member := s.group.JoinWithOptions(s.opts...)
+	w := bufio.NewWriter(conn)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer conn.Close()
+		hello := binary.AppendUvarint(nil, uint64(member.ID()))
+		if err := writeFrame(w, frame{kind: frameHello, body: hello}); err != nil {
+			return
+		}
+		for envelope := range member.Envelopes() {
+			body, err := bcast.MarshalEnvelope(s.codec, envelope)
+			if err != nil {
+				// The message can not be passed, the client
+				// should not see a gap in the sequence.
+				return
+			}
+			if err := writeFrame(w, frame{kind: frameMessage, body: body}); err != nil {
+				return
+			}
+		}
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		f, err := readFrame(r)
+		if err != nil || f.kind != frameSend {
+			break
+		}
+		var val T
+		if err := s.codec.Unmarshal(f.body, &val); err != nil {
+			break
+		}
+		if member.Send(val) != nil {
+			break
+		}
+	}
+	member.Close()
+	conn.Close()
+	<-done
+	s.lock.Lock()
+	delete(s.conns, conn)
+	s.lock.Unlock()
+}
@@ -0,0 +1,242 @@
+package bcast
+
+import (
+	"expvar"
+	"time"
+)
+
+// Observer receives notifications about the messages and the members
+// of a group. The callbacks are called synchronously by the senders,
+// the dispatcher and the member goroutines, so they must be fast and
+// safe for concurrent use. They are called without the locks of the
+// group held and may query the group, such as Stats or Members, but
+// OnEnqueue must not send to the group as the dispatcher waits for it.
+type Observer interface {
+	// OnSend is called when the group accepts a message. The sender
+	// is zero for the messages sent by the group itself.
+	OnSend(sender MemberID)
+	// OnEnqueue is called when the dispatcher stamps the message with
+	// the clock and puts it to the ring buffer read by the members.
+	OnEnqueue(clock int64)
+	// OnDeliver is called when the member receives the message.
+	// Latency is the time passed since the message was sent.
+	OnDeliver(member MemberID, clock int64, latency time.Duration)
+	// OnDrop is called when the message is dropped because the buffer
+	// of the member overflowed.
+	OnDrop(member MemberID, clock int64)
+	// OnJoin is called when the member joins the group.
+	OnJoin(member MemberID, clock int64)
+	// OnLeave is called when the member leaves or is evicted from the
+	// group.
+	OnLeave(member MemberID, clock int64)
+}
+
+// WithObserver adds the observer to the group. It may be given more
+// than once to add several observers.
+func WithObserver(observer Observer) GroupOption {
+	return func(o *groupOptions) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// GroupStats is a snapshot of the counters of a group.
+type GroupStats struct {
+	Clock   int64 // number of the messages stamped by the dispatcher
+	In      int64 // messages accepted by the group
+	Out     int64 // messages delivered to all the members
+	Dropped int64 // messages lost by all the members on overflow
+	Expired int64 // messages skipped by all the members on expiry
+	// Conflated is the number of the messages replaced by newer ones
+	// with the same key in the queues of the conflating members.
+	Conflated int64
+	Joined    int64 // members joined since the group was created
+	Left      int64 // members left or evicted
+	Evicted   int64 // members evicted
+	Members   []MemberStats
+	// MaxReorder is the largest number of the messages which were
+	// sent later than a message but stamped before it. It grows when
+	// concurrent senders race for the dispatcher.
+	MaxReorder int64
+}
+
+// MemberStats is a snapshot of the counters of a member.
+type MemberStats struct {
+	ID         MemberID
+	Name       string
+	Depth      int64 // messages in the ring not taken by the member yet
+	In         int64 // messages taken by the member for delivery
+	Out        int64 // messages delivered to the member
+	Dropped    int64
+	Expired    int64
+	Conflated  int64
+	Latency    time.Duration // average time from send to delivery
+	MaxLatency time.Duration
+}
+
+// Stats returns a snapshot of the counters of the group and of its
+// current members.
+func (g *TypedGroup[T]) Stats() GroupStats {
+	stats := GroupStats{
+		In:         g.accepted.Load(),
+		Out:        g.delivered.Load(),
+		Dropped:    g.dropped.Load(),
+		Expired:    g.expired.Load(),
+		Conflated:  g.conflated.Load(),
+		MaxReorder: g.maxReorder.Load(),
+	}
+	head := g.head.Load()
+	g.memberLock.Lock()
+	g.clockLock.Lock()
+	stats.Clock = g.clock
+	stats.Joined = g.joined
+	stats.Left = g.left
+	stats.Evicted = g.evicted
+	g.clockLock.Unlock()
+	members := append([]*TypedMember[T](nil), g.members...)
+	g.memberLock.Unlock()
+	for _, m := range members {
+		member := MemberStats{
+			ID:         m.id,
+			Name:       m.opts.name,
+			In:         m.received.Load(),
+			Out:        m.delivered.Load(),
+			Dropped:    m.drops.Load(),
+			Expired:    m.expiredCount.Load(),
+			Conflated:  m.conflated.Load(),
+			MaxLatency: time.Duration(m.maxLatency.Load()),
+		}
+		m.lock.Lock()
+		if depth := head - m.clock.Load() - int64(len(m.skipped)) + m.queued.Load(); depth > 0 {
+			member.Depth = depth
+		}
+		m.lock.Unlock()
+		if member.Out > 0 {
+			member.Latency = time.Duration(m.latency.Load() / member.Out)
+		}
+		stats.Members = append(stats.Members, member)
+	}
+	return stats
+}
+
+// Expvar returns the variable which reports the stats of the group as
+// JSON, to be published with expvar.Publish:
+//
+//	expvar.Publish("bcast.prices", group.Expvar())
+func (g *TypedGroup[T]) Expvar() expvar.Var {
+	return expvar.Func(func() any {
+		return g.Stats()
+	})
+}
+
+func (g *TypedGroup[T]) observeSend(sender *TypedMember[T]) {
+	g.accepted.Add(1)
+	if len(g.opts.observers) == 0 {
+		return
+	}
+	var id MemberID
+	if sender != nil {
+		id = sender.id
+	}
+	for _, o := range g.opts.observers {
+		o.OnSend(id)
+	}
+}
+
+// observeEnqueue measures the reorder distance of the message just
+// stamped. It is called by the dispatcher under the dispatch lock
+// before the message is published.
+func (g *TypedGroup[T]) observeEnqueue(r *ring[T], message *Message[T]) {
+	var distance int64
+	for clock := message.clock - 1; clock >= 0 && message.clock-clock < r.size(); clock-- {
+		previous := r.get(clock)
+		if previous == nil || !previous.sent.After(message.sent) {
+			break
+		}
+		distance++
+	}
+	if distance > g.maxReorder.Load() {
+		g.maxReorder.Store(distance)
+	}
+	for _, o := range g.opts.observers {
+		o.OnEnqueue(message.clock)
+	}
+}
+
+// observeMembership counts the membership change and queues it for
+// the observers. It is called under the member and clock locks, the
+// caller notifies the observers after it releases them.
+func (g *TypedGroup[T]) observeMembership(event MembershipEvent) {
+	switch event.Kind {
+	case Joined:
+		g.joined++
+	case Left, Evicted:
+		g.left++
+		if event.Kind == Evicted {
+			g.evicted++
+		}
+	default:
+		return
+	}
+	if len(g.opts.observers) > 0 {
+		g.noticed = append(g.noticed, event)
+	}
+}
+
+// notify passes the queued membership changes to the observers. The
+// goroutine holding the notify lock passes the changes queued
+// meanwhile by the others and checks for the new ones after releasing
+// it, so the observers get the changes in order and none is left
+// behind.
+func (g *TypedGroup[T]) notify() {
+	for {
+		g.memberLock.Lock()
+		noticed := len(g.noticed)
+		g.memberLock.Unlock()
+		if noticed == 0 || !g.notifyLock.TryLock() {
+			return
+		}
+		for {
+			g.memberLock.Lock()
+			events := g.noticed
+			g.noticed = nil
+			g.memberLock.Unlock()
+			if len(events) == 0 {
+				break
+			}
+			for _, event := range events {
+				for _, o := range g.opts.observers {
+					if event.Kind == Joined {
+						o.OnJoin(event.Member, event.Clock)
+					} else {
+						o.OnLeave(event.Member, event.Clock)
+					}
+				}
+			}
+		}
+		g.notifyLock.Unlock()
+	}
+}
+
+func (m *TypedMember[T]) observeDeliver(message *Message[T]) {
+	latency := time.Since(message.sent)
+	m.delivered.Add(1)
+	m.group.delivered.Add(1)
+	m.latency.Add(int64(latency))
+	for {
+		longest := m.maxLatency.Load()
+		if int64(latency) <= longest || m.maxLatency.CompareAndSwap(longest, int64(latency)) {
+			break
+		}
+	}
+	for _, o := range m.group.opts.observers {
+		o.OnDeliver(m.id, message.clock, latency)
+	}
+}
+
+func (m *TypedMember[T]) observeDrop(clock int64) {
+	m.drops.Add(1)
+	m.group.dropped.Add(1)
+	for _, o := range m.group.opts.observers {
+		o.OnDrop(m.id, clock)
+	}
+}
@@ -0,0 +1,164 @@
+package bcast
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.lock.Lock()
+	r.events = append(r.events, event)
+	r.lock.Unlock()
+}
+
+func (r *recorder) count(event string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e == event {
+			n++
+		}
+	}
+	return n
+}
+
+// waitStats polls the stats of the group until the check passes.
+func waitStats[T any](t *testing.T, group *TypedGroup[T], check func(GroupStats) bool) GroupStats {
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := group.Stats()
+		if check(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (r *recorder) OnSend(sender MemberID)                                  { r.add("send") }
+func (r *recorder) OnEnqueue(clock int64)                                   { r.add("enqueue") }
+func (r *recorder) OnDeliver(member MemberID, clock int64, _ time.Duration) { r.add("deliver") }
+func (r *recorder) OnDrop(member MemberID, clock int64)                     { r.add("drop") }
+func (r *recorder) OnJoin(member MemberID, clock int64)                     { r.add("join") }
+func (r *recorder) OnLeave(member MemberID, clock int64)                    { r.add("leave") }
+
+// Create new broadcast group with an observer.
+// Send messages to a fast and a lagging member.
+// Check the callbacks and the stats snapshot.
+func TestObserver(t *testing.T) {
+	r := &recorder{}
+	group := NewGroupOf[int](WithObserver(r))
+	fast := group.Join()
+	slow := group.JoinWithOptions(WithName("slow"), WithBufferSize(2), WithOverflowPolicy(DropNewest))
+	go group.Broadcast(0)
+
+	for i := 0; i < 5; i++ {
+		group.Send(i)
+		fast.Recv()
+	}
+	// Delivery is counted after the value is passed to the reader,
+	// the message taken by the listener of the slow member is counted
+	// after it leaves the ring.
+	stats := waitStats(t, group, func(stats GroupStats) bool {
+		for _, member := range stats.Members {
+			if member.ID == slow.ID() && member.In+member.Depth+member.Dropped != 5 {
+				return false
+			}
+		}
+		return stats.Out == 5
+	})
+	if stats.Clock != 5 || stats.In != 5 || stats.Joined != 2 || len(stats.Members) != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	for _, member := range stats.Members {
+		switch member.ID {
+		case fast.ID():
+			if member.Out != 5 || member.Depth != 0 {
+				t.Fatalf("unexpected fast member stats %+v", member)
+			}
+		case slow.ID():
+			// The listener holds at most one message and two wait
+			// in the ring, the rest are dropped.
+			if member.Name != "slow" || member.Dropped < 2 {
+				t.Fatalf("unexpected slow member stats %+v", member)
+			}
+		}
+	}
+	slow.Close()
+	if r.count("send") != 5 || r.count("enqueue") != 5 || r.count("drop") < 2 ||
+		r.count("join") != 2 || r.count("leave") != 1 || r.count("deliver") < 5 {
+		t.Fatalf("unexpected callbacks %v", r.events)
+	}
+	group.Close()
+}
+
+// Create new broadcast group and export it.
+// Check that the exported variable is the JSON of the stats.
+func TestExpvar(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.Join()
+	go group.Broadcast(0)
+	group.Send(1)
+	member.Recv()
+
+	waitStats(t, group, func(stats GroupStats) bool { return stats.Out == 1 })
+	var stats GroupStats
+	if err := json.Unmarshal([]byte(group.Expvar().String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Out != 1 || len(stats.Members) != 1 || stats.Members[0].Out != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	group.Close()
+}
+
+// querier queries the group from the membership callbacks.
+type querier struct {
+	recorder
+	group *TypedGroup[int]
+}
+
+func (q *querier) OnJoin(member MemberID, clock int64) {
+	q.add("join")
+	q.group.MemberCount()
+	q.group.Stats()
+}
+
+func (q *querier) OnLeave(member MemberID, clock int64) {
+	q.add("leave")
+	q.group.Members()
+}
+
+// Create new broadcast group with an observer which queries the group.
+// Join and leave the group.
+// Check that the callbacks do not deadlock.
+func TestObserverQueries(t *testing.T) {
+	q := &querier{}
+	q.group = NewGroupOf[int](WithObserver(q))
+	done := make(chan bool)
+	go func() {
+		member := q.group.Join()
+		q.group.Join()
+		member.Close()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("observer deadlocks the group")
+	}
+	if q.count("join") != 2 || q.count("leave") != 1 {
+		t.Fatalf("unexpected callbacks %v", q.events)
+	}
+	q.group.Close()
+}
@@ -0,0 +1,180 @@
+package bcast
+
+import (
+	"context"
+	"time"
+)
+
+// ClosePolicy defines what happens to the messages which were
+// accepted by the group but not yet delivered to a member when the
+// group is closed.
+type ClosePolicy int
+
+const (
+	// DropOnClose discards undelivered messages and closes the Read
+	// channels of the members immediately. This is the default.
+	DropOnClose ClosePolicy = iota
+	// DrainOnClose delivers every message accepted by the group
+	// before Close and only then closes the Read channels.
+	DrainOnClose
+)
+
+// GroupOption configures a Group created by NewGroup or NewGroupOf.
+type GroupOption func(*groupOptions)
+
+type groupOptions struct {
+	closePolicy     ClosePolicy
+	ringSize        int
+	retainLast      int
+	observers       []Observer
+	tracer          Tracer
+	codec           Codec
+	segmentSize     int64
+	logMaxSize      int64
+	logMaxAge       time.Duration
+	logSync         bool
+	logSyncInterval time.Duration
+	starvationGuard int
+}
+
+// WithClosePolicy sets the policy applied to the undelivered messages
+// when the group is closed.
+func WithClosePolicy(policy ClosePolicy) GroupOption {
+	return func(o *groupOptions) {
+		o.closePolicy = policy
+	}
+}
+
+// WithRingSize sets the initial size of the ring buffer which holds
+// the messages until all the members read them. The ring grows when a
+// member with unlimited buffer falls behind by the whole ring.
+// DefaultRingSize is used by default.
+func WithRingSize(size int) GroupOption {
+	return func(o *groupOptions) {
+		o.ringSize = size
+	}
+}
+
+// WithRetainLast makes the group keep the last n messages and replay
+// them to every new member before the messages sent after it joined.
+func WithRetainLast(n int) GroupOption {
+	return func(o *groupOptions) {
+		o.retainLast = n
+	}
+}
+
+// OverflowPolicy defines what happens when the dispatcher delivers a
+// message to a member which buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest message waiting in the buffer.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the message being delivered.
+	DropNewest
+	// Block makes the dispatcher wait until the member reads a
+	// message, so the whole group slows down to the pace of the
+	// member. Sending from the goroutine which reads the member may
+	// deadlock then.
+	Block
+	// Evict removes the member from the group.
+	Evict
+)
+
+// MemberOption configures a Member created by Group.JoinWithOptions.
+type MemberOption func(*memberOptions)
+
+type memberOptions struct {
+	bufferSize     int
+	overflowPolicy OverflowPolicy
+	topics         []string
+	requests       bool
+	envelopes      bool
+	name           string
+	echo           bool
+	replay         bool
+	replayFrom     int64
+	durable        *durable
+	ackTimeout     time.Duration
+}
+
+// WithBufferSize limits the number of messages waiting for the member
+// to read them. Zero size means unlimited buffer, it is the default.
+func WithBufferSize(size int) MemberOption {
+	return func(o *memberOptions) {
+		o.bufferSize = size
+	}
+}
+
+// WithOverflowPolicy sets the policy applied when the buffer of the
+// member is full. DropOldest is used by default.
+func WithOverflowPolicy(policy OverflowPolicy) MemberOption {
+	return func(o *memberOptions) {
+		o.overflowPolicy = policy
+	}
+}
+
+// WithTopics subscribes the member to the topic patterns before it
+// starts receiving, so the retained messages published on the topics
+// are replayed to it too.
+func WithTopics(patterns ...string) MemberOption {
+	return func(o *memberOptions) {
+		o.topics = append(o.topics, patterns...)
+	}
+}
+
+// WithEnvelopes makes the member receive the envelopes carrying the
+// payloads together with the sender, clock, time and headers of the
+// messages from the channel returned by Member.Envelopes. Read
+// channel of such member stays empty.
+func WithEnvelopes() MemberOption {
+	return func(o *memberOptions) {
+		o.envelopes = true
+	}
+}
+
+// WithName sets the name of the member, it is not required to be
+// unique.
+func WithName(name string) MemberOption {
+	return func(o *memberOptions) {
+		o.name = name
+	}
+}
+
+// WithEcho makes the member receive its own messages in the group
+// order together with the messages of the other members. By default
+// members don't receive what they send. Requests are never echoed.
+func WithEcho() MemberOption {
+	return func(o *memberOptions) {
+		o.echo = true
+	}
+}
+
+// WithRequests makes the member receive the requests sent by the
+// other members from the channel returned by Member.Requests.
+// Members without this option don't receive requests at all.
+func WithRequests() MemberOption {
+	return func(o *memberOptions) {
+		o.requests = true
+	}
+}
+
+// SendOption configures a single message sent to a group.
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	headers  map[string]string
+	trace    context.Context
+	deadline time.Time
+}
+
+// WithHeader adds the header delivered in the envelope of the
+// message.
+func WithHeader(key, value string) SendOption {
+	return func(o *sendOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		o.headers[key] = value
+	}
+}
@@ -0,0 +1,152 @@
+package bcast
+
+import (
+	"context"
+)
+
+// WithStarvationGuard limits the number of the higher priority
+// messages delivered to a member in a row while an older message of a
+// lower priority waits. When the limit is reached the oldest waiting
+// message is delivered next. Zero disables the guard, it is the
+// default.
+func WithStarvationGuard(n int) GroupOption {
+	return func(o *groupOptions) {
+		o.starvationGuard = n
+	}
+}
+
+// SendPriority broadcasts a message with the priority level. Messages
+// with higher levels are delivered to a member ahead of the messages
+// with lower levels waiting for the member. Messages of the same level
+// are delivered in the group order. Ordinary messages have level 0.
+func (g *TypedGroup[T]) SendPriority(val T, level int, opts ...SendOption) error {
+	return g.send(context.Background(), g.prioritize(newMessage(nil, val, opts), level))
+}
+
+// SendPriority broadcasts a message with the priority level from the
+// member to the other members of its group.
+func (m *TypedMember[T]) SendPriority(val T, level int, opts ...SendOption) error {
+	return m.group.send(context.Background(), m.group.prioritize(newMessage(m, val, opts), level))
+}
+
+func (g *TypedGroup[T]) prioritize(message Message[T], level int) Message[T] {
+	message.priority = level
+	if level != 0 {
+		g.prioritized.Store(true)
+	}
+	return message
+}
+
+// next returns the next message to deliver to the member or nil if
+// there is none. Until the first message with a priority is sent the
+// messages are taken from the ring in order unless the member
+// conflates them. After that the member moves the messages waiting in
+// the ring to its priority queue and takes the top one. Expired
+// messages are skipped, the clock of the member advances past them as
+// past the filtered out ones.
+func (m *TypedMember[T]) next() *Message[T] {
+	if !m.group.prioritized.Load() && m.conflate == nil {
+		for {
+			message := m.nextMessage()
+			if message == nil || m.wants(message) && !m.expire(message) {
+				return message
+			}
+		}
+	}
+	// The messages in the priority queue count to the buffer size
+	// of the member as the ones in the ring do, so reading ahead
+	// never holds more than the buffer allows.
+	for {
+		message := m.nextMessage()
+		if message == nil {
+			break
+		}
+		if m.wants(message) {
+			m.lock.Lock()
+			m.enqueue(message)
+			m.lock.Unlock()
+		}
+	}
+	for {
+		m.lock.Lock()
+		if m.lanes.Len() == 0 {
+			m.lock.Unlock()
+			return nil
+		}
+		message := m.popLane()
+		m.lock.Unlock()
+		if m.opts.bufferSize > 0 {
+			signal(m.room)
+		}
+		if !m.expire(message) {
+			return message
+		}
+	}
+}
+
+// popLane takes the message from the top of the priority queue unless
+// the starvation guard picks the oldest one. It is called under the
+// lock of the member.
+func (m *TypedMember[T]) popLane() *Message[T] {
+	if guard := m.group.opts.starvationGuard; guard > 0 {
+		oldest := m.lanes.oldest()
+		if oldest == 0 {
+			m.bypassed = 0
+		} else if m.bypassed++; m.bypassed > guard {
+			m.bypassed = 0
+			return m.dequeue(oldest)
+		}
+	}
+	return m.dequeue(0)
+}
+
+// laneItem is a message waiting in the priority queue of a member.
+type laneItem[T any] struct {
+	message *Message[T]
+	index   int // maintained by the heap.Interface methods
+}
+
+// laneQueue is the priority queue of the messages taken from the ring
+// by a member. Higher priorities come first, the messages of the same
+// priority keep the group order.
+type laneQueue[T any] []*laneItem[T]
+
+func (q laneQueue[T]) Len() int { return len(q) }
+
+func (q laneQueue[T]) Less(i, j int) bool {
+	if q[i].message.priority != q[j].message.priority {
+		return q[i].message.priority > q[j].message.priority
+	}
+	return q[i].message.clock < q[j].message.clock
+}
+
+func (q laneQueue[T]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *laneQueue[T]) Push(x any) {
+	item := x.(*laneItem[T])
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *laneQueue[T]) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
+
+// oldest returns the index of the message with the lowest clock.
+func (q laneQueue[T]) oldest() int {
+	oldest := 0
+	for i, item := range q {
+		if item.message.clock < q[oldest].message.clock {
+			oldest = i
+		}
+	}
+	return oldest
+}
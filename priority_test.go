@@ -0,0 +1,84 @@
+package bcast
+
+import (
+	"testing"
+)
+
+// sendHeld sends the values while the listener of the member is held
+// by the first message it has taken, so all of them wait in the ring.
+func sendHeld(t *testing.T, group *TypedGroup[int], send func()) {
+	group.Send(-1)
+	waitStats(t, group, func(stats GroupStats) bool { return stats.Members[0].In == 1 })
+	send()
+	clock := group.sentClock()
+	waitStats(t, group, func(stats GroupStats) bool { return stats.Members[0].Depth == clock-1 })
+}
+
+func expectOrder(t *testing.T, member *TypedMember[int], expected ...int) {
+	for _, e := range expected {
+		if val := member.Recv(); val != e {
+			t.Fatalf("expected %d, got %d", e, val)
+		}
+	}
+}
+
+// Create new broadcast group.
+// Send messages of different levels while the member does not read.
+// Check that higher levels come first and each level keeps its order.
+func TestSendPriority(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.Join()
+	go group.Broadcast(0)
+	sendHeld(t, group, func() {
+		group.Send(1)
+		group.SendPriority(10, 1)
+		group.Send(2)
+		group.SendPriority(20, 2)
+		group.SendPriority(11, 1)
+	})
+	expectOrder(t, member, -1, 20, 10, 11, 1, 2)
+	group.Close()
+}
+
+// Create new broadcast group with the starvation guard.
+// Send a low level message followed by many urgent ones.
+// Check that the low level message is not delayed beyond the guard.
+func TestStarvationGuard(t *testing.T) {
+	group := NewGroupOf[int](WithStarvationGuard(2))
+	member := group.Join()
+	go group.Broadcast(0)
+	sendHeld(t, group, func() {
+		group.Send(1)
+		group.Send(2)
+		for i := 10; i < 15; i++ {
+			group.SendPriority(i, 1)
+		}
+	})
+	expectOrder(t, member, -1, 10, 11, 1, 12, 13, 2, 14)
+	group.Close()
+}
+
+// Create new broadcast group with a bounded member dropping the oldest.
+// Fill the priority queue of the member and send more messages.
+// Check that the queued messages count to the depth and to the buffer.
+func TestPriorityBufferSize(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.JoinWithOptions(WithBufferSize(3), WithOverflowPolicy(DropOldest))
+	go group.Broadcast(0)
+	group.SendPriority(-1, 1)
+	waitStats(t, group, func(stats GroupStats) bool { return stats.Members[0].In == 1 })
+	for i := 1; i <= 3; i++ {
+		group.Send(i)
+	}
+	waitStats(t, group, func(stats GroupStats) bool { return stats.Members[0].Depth == 3 })
+	expectOrder(t, member, -1)
+	// The member holds 1 and queues 2 and 3.
+	waitStats(t, group, func(stats GroupStats) bool {
+		return stats.Members[0].In == 2 && stats.Members[0].Depth == 2
+	})
+	group.Send(4)
+	group.Send(5)
+	waitStats(t, group, func(stats GroupStats) bool { return stats.Members[0].Dropped == 1 })
+	expectOrder(t, member, 1, 3, 4, 5)
+	group.Close()
+}
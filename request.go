@@ -0,0 +1,133 @@
+package bcast
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRequestDone is returned when replying to a request which is not
+// waiting for replies anymore.
+var ErrRequestDone = errors.New("Request is done")
+
+// Request is a message sent by Member.Request to the members joined
+// with WithRequests option. They receive it from Requests channel and
+// answer with Reply.
+type Request[T any] struct {
+	Payload T
+	From    *TypedMember[T] // the requesting member
+	to      *TypedMember[T]
+	call    *call[T]
+}
+
+// call is shared by all the copies of a request delivered to the
+// members.
+type call[T any] struct {
+	replies chan Reply[T]
+	done    chan struct{}
+}
+
+// Reply is an answer to a request.
+type Reply[T any] struct {
+	Payload T
+	From    *TypedMember[T] // the replying member
+}
+
+// RequestOption configures Member.Request.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	quorum int
+}
+
+// WithQuorum makes the request return as soon as n replies received.
+// By default the request waits for the replies from all the members
+// which accept requests.
+func WithQuorum(n int) RequestOption {
+	return func(o *requestOptions) {
+		o.quorum = n
+	}
+}
+
+// Request broadcasts the value to the other members of the group
+// which accept requests and collects their replies. It returns when
+// the quorum or all of the members replied, or when ctx is done. In
+// the latter case the replies collected so far are returned together
+// with ctx.Err(), so a request without a deadline waits forever for
+// a member which never replies.
+func (m *TypedMember[T]) Request(ctx context.Context, val T, opts ...RequestOption) ([]Reply[T], error) {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	expected := o.quorum
+	if expected <= 0 {
+		for _, member := range m.group.Members() {
+			if member != m && member.requests != nil {
+				expected++
+			}
+		}
+	}
+	c := &call[T]{
+		replies: make(chan Reply[T]),
+		done:    make(chan struct{}),
+	}
+	defer close(c.done)
+	message := newMessage(m, val, nil)
+	message.call = c
+	err := m.group.send(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	var replies []Reply[T]
+	for len(replies) < expected {
+		select {
+		case reply := <-c.replies:
+			replies = append(replies, reply)
+		case <-ctx.Done():
+			return replies, ctx.Err()
+		}
+	}
+	return replies, nil
+}
+
+// Requests returns the channel of the requests sent by the other
+// members. It is nil unless the member joined with WithRequests
+// option. The channel is closed when the member leaves the group.
+func (m *TypedMember[T]) Requests() <-chan *Request[T] {
+	return m.requests
+}
+
+// Reply answers the request to the requesting member. It returns
+// ErrRequestDone if the requesting member doesn't wait for replies
+// anymore.
+func (r *Request[T]) Reply(val T) error {
+	select {
+	case r.call.replies <- Reply[T]{Payload: val, From: r.to}:
+		return nil
+	case <-r.call.done:
+		return ErrRequestDone
+	}
+}
+
+// deliverRequest passes the request to the Requests channel unless
+// the member is stopped.
+func (m *TypedMember[T]) deliverRequest(message *Message[T]) {
+	request := &Request[T]{
+		Payload: message.payload,
+		From:    message.sender,
+		to:      m,
+		call:    message.call,
+	}
+	for {
+		select {
+		case m.requests <- request:
+		case b := <-m.batches:
+			// The values queued after the request wait for it.
+			m.batch = b
+			m.flushBatch(false)
+			continue
+		case <-m.quit:
+		}
+		return
+	}
+}
@@ -0,0 +1,76 @@
+package bcast
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Create new broadcast group.
+// Join 3 members answering requests and one which doesn't.
+// Check that the request collects replies from all of them.
+func TestRequest(t *testing.T) {
+	group := NewGroupOf[int]()
+	requester := group.Join()
+	silent := group.Join()
+	for i := 1; i <= 3; i++ {
+		m := group.JoinWithOptions(WithRequests())
+		go func(factor int) {
+			for request := range m.Requests() {
+				if request.From != requester {
+					t.Error("request must be bound to the requester")
+				}
+				request.Reply(request.Payload * factor)
+			}
+		}(i)
+	}
+	go group.Broadcast(0)
+
+	replies, err := requester.Request(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for _, reply := range replies {
+		sum += reply.Payload
+		if reply.From == requester || reply.From == silent {
+			t.Fatal("reply from the member which doesn't answer requests")
+		}
+	}
+	if len(replies) != 3 || sum != 60 {
+		t.Fatalf("expected 3 replies with sum 60, got %v", replies)
+	}
+
+	replies, err = requester.Request(context.Background(), 1, WithQuorum(1))
+	if err != nil || len(replies) != 1 {
+		t.Fatalf("expected 1 reply, got %v, %v", replies, err)
+	}
+	group.Close()
+}
+
+// Create new broadcast group.
+// Join members where one never replies.
+// Check that the request returns partial replies on deadline.
+func TestRequestDeadline(t *testing.T) {
+	group := NewGroupOf[string]()
+	requester := group.Join()
+	answering := group.JoinWithOptions(WithRequests())
+	group.JoinWithOptions(WithRequests()) // never reads requests
+	go func() {
+		for request := range answering.Requests() {
+			request.Reply("pong")
+		}
+	}()
+	go group.Broadcast(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	replies, err := requester.Request(ctx, "ping")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if len(replies) != 1 || replies[0].Payload != "pong" || replies[0].From != answering {
+		t.Fatalf("expected one reply, got %v", replies)
+	}
+	group.Close()
+}
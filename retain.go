@@ -0,0 +1,74 @@
+package bcast
+
+import (
+	"sort"
+)
+
+// Keyer returns the key of the payload. Messages with equal keys are
+// treated as the versions of the same value.
+type Keyer[T any] func(payload T) string
+
+// retention keeps the messages replayed to the members joining the
+// group. It is guarded by the clock lock of the group.
+type retention[T any] struct {
+	last  []*Message[T] // circular buffer of the last messages
+	next  int
+	keyer Keyer[T]
+	byKey map[string]*Message[T]
+}
+
+func newRetention[T any](last int) *retention[T] {
+	if last <= 0 {
+		return nil
+	}
+	return &retention[T]{last: make([]*Message[T], 0, last)}
+}
+
+// RetainByKey makes the group keep the last message for each key
+// returned by the keyer and replay them to every new member before
+// the messages sent after it joined. It may be combined with
+// WithRetainLast, the replayed messages are ordered by their clocks.
+// Only the messages sent after the call are retained by key, so it is
+// meant to be called before the group is used.
+func (g *TypedGroup[T]) RetainByKey(keyer Keyer[T]) {
+	g.clockLock.Lock()
+	defer g.clockLock.Unlock()
+	if g.retained == nil {
+		g.retained = &retention[T]{}
+	}
+	g.retained.keyer = keyer
+	g.retained.byKey = make(map[string]*Message[T])
+}
+
+func (r *retention[T]) add(message *Message[T]) {
+	if cap(r.last) > 0 {
+		if len(r.last) < cap(r.last) {
+			r.last = append(r.last, message)
+		} else {
+			r.last[r.next] = message
+			r.next = (r.next + 1) % cap(r.last)
+		}
+	}
+	if r.keyer != nil {
+		r.byKey[r.keyer(message.payload)] = message
+	}
+}
+
+// messages returns the retained messages in clock order.
+func (r *retention[T]) messages() []*Message[T] {
+	var retained []*Message[T]
+	seen := make(map[*Message[T]]bool)
+	for _, message := range r.last {
+		retained = append(retained, message)
+		seen[message] = true
+	}
+	for _, message := range r.byKey {
+		if !seen[message] {
+			retained = append(retained, message)
+		}
+	}
+	sort.Slice(retained, func(i, j int) bool {
+		return retained[i].clock < retained[j].clock
+	})
+	return retained
+}
@@ -0,0 +1,65 @@
+package bcast
+
+import (
+	"testing"
+)
+
+// Create new broadcast group which retains last 3 messages.
+// Send 5 messages before the member joins.
+// Check that the last 3 are replayed before the live ones.
+func TestRetainLast(t *testing.T) {
+	group := NewGroupOf[int](WithRetainLast(3))
+	go group.Broadcast(0)
+	for i := 0; i < 5; i++ {
+		group.Send(i)
+	}
+	member := group.Join()
+	group.Send(5)
+	for i := 2; i <= 5; i++ {
+		if val := member.Recv(); val != i {
+			t.Fatalf("expected %d, got %d", i, val)
+		}
+	}
+	group.Close()
+}
+
+// Create new broadcast group which retains last value per key.
+// Send several versions of values before the member joins.
+// Check that the last versions are replayed in order.
+func TestRetainByKey(t *testing.T) {
+	type setting struct{ key, value string }
+	group := NewGroupOf[setting]()
+	group.RetainByKey(func(payload setting) string {
+		return payload.key
+	})
+	go group.Broadcast(0)
+	group.Send(setting{"a", "1"})
+	group.Send(setting{"b", "1"})
+	group.Send(setting{"a", "2"})
+	group.Send(setting{"c", "1"})
+	member := group.JoinWithOptions()
+	for _, expected := range []setting{{"b", "1"}, {"a", "2"}, {"c", "1"}} {
+		if val := member.Recv(); val != expected {
+			t.Fatalf("expected %v, got %v", expected, val)
+		}
+	}
+	group.Close()
+}
+
+// Create new broadcast group which retains messages on topics.
+// Join member subscribed to one of the topics.
+// Check that only the matching messages are replayed.
+func TestRetainTopics(t *testing.T) {
+	group := NewGroupOf[string](WithRetainLast(10))
+	go group.Broadcast(0)
+	group.Publish("config.db", "db")
+	group.Publish("metrics.cpu", "cpu")
+	group.Publish("config.cache", "cache")
+	member := group.JoinWithOptions(WithTopics("config.*"))
+	for _, expected := range []string{"db", "cache"} {
+		if val := member.Recv(); val != expected {
+			t.Fatalf("expected %q, got %q", expected, val)
+		}
+	}
+	group.Close()
+}
@@ -0,0 +1,61 @@
+package bcast
+
+import (
+	"sync/atomic"
+)
+
+// DefaultRingSize is the initial number of slots in the ring buffer
+// of a group.
+const DefaultRingSize = 1024
+
+// ring is a sequence-numbered ring buffer of the messages shared by
+// all the members of a group, disruptor-style. The dispatcher writes
+// each message to the slot chosen by its clock and every member reads
+// the slots at its own cursor. The clock of the message found in a
+// slot is checked on read, so a reader never takes a message from
+// another lap of the ring.
+type ring[T any] struct {
+	slots []atomic.Pointer[Message[T]]
+	mask  int64
+}
+
+// newRing creates a ring with the size rounded up to a power of two.
+func newRing[T any](size int) *ring[T] {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &ring[T]{
+		slots: make([]atomic.Pointer[Message[T]], n),
+		mask:  int64(n - 1),
+	}
+}
+
+func (r *ring[T]) size() int64 {
+	return int64(len(r.slots))
+}
+
+func (r *ring[T]) put(message *Message[T]) {
+	r.slots[message.clock&r.mask].Store(message)
+}
+
+// get returns the message stamped with the clock or nil if its slot
+// was overwritten.
+func (r *ring[T]) get(clock int64) *Message[T] {
+	message := r.slots[clock&r.mask].Load()
+	if message == nil || message.clock != clock {
+		return nil
+	}
+	return message
+}
+
+// grow returns a ring of the double size holding the same messages.
+func (r *ring[T]) grow() *ring[T] {
+	bigger := newRing[T](2 * len(r.slots))
+	for i := range r.slots {
+		if message := r.slots[i].Load(); message != nil {
+			bigger.put(message)
+		}
+	}
+	return bigger
+}
@@ -0,0 +1,106 @@
+package bcast
+
+import (
+	"context"
+	"strings"
+)
+
+// Publish broadcasts a message on the topic to the members of the
+// Group subscribed to it. It returns ErrGroupClosed if the group is
+// closed.
+func (g *TypedGroup[T]) Publish(topic string, val T, opts ...SendOption) error {
+	message := newMessage(nil, val, opts)
+	message.topic = topic
+	return g.send(context.Background(), message)
+}
+
+// Publish broadcasts a message on the topic from one Member to the
+// other members of its group subscribed to it. It returns
+// ErrGroupClosed if the group is closed.
+func (m *TypedMember[T]) Publish(topic string, val T, opts ...SendOption) error {
+	message := newMessage(m, val, opts)
+	message.topic = topic
+	return m.group.send(context.Background(), message)
+}
+
+// Subscribe adds the topic patterns the member receives published
+// messages for. Topics consist of tokens separated by dots. Pattern
+// token "*" matches any single token and token ">" at the end of the
+// pattern matches one or more tokens, so "orders.*" matches
+// "orders.new" but not "orders.new.eu" while "orders.>" matches both.
+// Messages sent without a topic are delivered to all the members
+// regardless of their subscriptions.
+func (m *TypedMember[T]) Subscribe(patterns ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var topics []string
+	if current := m.topics.Load(); current != nil {
+		topics = append(topics, *current...)
+	}
+	for _, pattern := range patterns {
+		if !containsString(topics, pattern) {
+			topics = append(topics, pattern)
+		}
+	}
+	m.topics.Store(&topics)
+}
+
+// Unsubscribe removes the topic patterns added by Subscribe.
+func (m *TypedMember[T]) Unsubscribe(patterns ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	current := m.topics.Load()
+	if current == nil {
+		return
+	}
+	var topics []string
+	for _, topic := range *current {
+		if !containsString(patterns, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	m.topics.Store(&topics)
+}
+
+// subscribed reports whether the member has a pattern matching the
+// topic.
+func (m *TypedMember[T]) subscribed(topic string) bool {
+	topics := m.topics.Load()
+	if topics == nil {
+		return false
+	}
+	for _, pattern := range *topics {
+		if matchTopic(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTopic reports whether the topic matches the pattern.
+func matchTopic(pattern, topic string) bool {
+	for {
+		var p, t string
+		var pmore, tmore bool
+		p, pattern, pmore = strings.Cut(pattern, ".")
+		if p == ">" && !pmore {
+			return topic != ""
+		}
+		t, topic, tmore = strings.Cut(topic, ".")
+		if p != "*" && p != t || pmore != tmore {
+			return false
+		}
+		if !pmore {
+			return true
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
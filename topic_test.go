@@ -0,0 +1,70 @@
+package bcast
+
+import (
+	"testing"
+)
+
+func TestMatchTopic(t *testing.T) {
+	for _, c := range []struct {
+		pattern, topic string
+		match          bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "order", false},
+		{"orders.new", "orders.new", true},
+		{"orders.new", "orders", false},
+		{"orders", "orders.new", false},
+		{"orders.*", "orders.new", true},
+		{"orders.*", "orders.new.eu", false},
+		{"orders.*", "orders", false},
+		{"*.new", "orders.new", true},
+		{"*.new", "payments.new", true},
+		{"*.new", "orders.old", false},
+		{"orders.>", "orders.new", true},
+		{"orders.>", "orders.new.eu", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+		{">", "orders.new", true},
+		{"orders.*.eu", "orders.new.eu", true},
+		{"orders.*.eu", "orders.new.us", false},
+	} {
+		if matchTopic(c.pattern, c.topic) != c.match {
+			t.Errorf("pattern %q, topic %q: expected match %v", c.pattern, c.topic, c.match)
+		}
+	}
+}
+
+// Create new broadcast group.
+// Subscribe members to different topics.
+// Publish on the topics and check that only matching members receive.
+func TestPublish(t *testing.T) {
+	group := NewGroupOf[string]()
+	orders := group.Join()
+	orders.Subscribe("orders.>")
+	newOrders := group.Join()
+	newOrders.Subscribe("orders.new", "payments.*")
+	everyone := group.Join()
+	go group.Broadcast(0)
+
+	group.Publish("orders.new", "order 1")
+	group.Publish("orders.paid.eu", "order 2")
+	group.Publish("payments.new", "payment 1")
+	group.Send("to all")
+
+	expect := func(member *TypedMember[string], expected ...string) {
+		for _, e := range expected {
+			if val := member.Recv(); val != e {
+				t.Fatalf("expected %q, got %q", e, val)
+			}
+		}
+	}
+	expect(orders, "order 1", "order 2", "to all")
+	expect(newOrders, "order 1", "payment 1", "to all")
+	expect(everyone, "to all")
+
+	newOrders.Unsubscribe("payments.*")
+	group.Publish("payments.new", "payment 2")
+	group.Publish("orders.new", "order 3")
+	expect(newOrders, "order 3")
+	group.Close()
+}
@@ -0,0 +1,171 @@
+package bcast
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Names of the spans started by a group for every message.
+const (
+	// SpanEnqueue covers the wait of the sender for the dispatcher.
+	SpanEnqueue = "bcast.enqueue"
+	// SpanDispatch covers stamping the message and publishing it to
+	// the members.
+	SpanDispatch = "bcast.dispatch"
+	// SpanDeliver covers the wait of the message for the reader of a
+	// member. There is one deliver span for every receiving member.
+	SpanDeliver = "bcast.deliver"
+)
+
+// Keys of the span attributes.
+const (
+	AttrClock  = "bcast.clock"
+	AttrSender = "bcast.sender"
+	AttrMember = "bcast.member"
+)
+
+// Tracer starts the spans of the messages passing through a group. It
+// follows the shape of the OpenTelemetry tracer, so an adapter to the
+// OpenTelemetry SDK takes a few lines. The span started by Start must
+// be a child of the span found in ctx, if any, and the returned
+// context must carry the new span.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	End()
+}
+
+// Attribute is a key and value pair attached to a span.
+type Attribute struct {
+	Key   string
+	Value int64
+}
+
+// WithTracer makes the group trace every message from the send to the
+// delivery. The trace context of a message is taken from the context
+// passed to SendContext or set by WithTraceContext. Members joined
+// with WithEnvelopes receive the context of the deliver span in the
+// envelope.
+func WithTracer(tracer Tracer) GroupOption {
+	return func(o *groupOptions) {
+		o.tracer = tracer
+	}
+}
+
+// WithTraceContext sets the context carrying the parent span of the
+// message. It is useful with the methods which have no context
+// argument, such as Send and Publish.
+func WithTraceContext(ctx context.Context) SendOption {
+	return func(o *sendOptions) {
+		o.trace = ctx
+	}
+}
+
+// traceEnqueue starts the enqueue span of the message and keeps its
+// context in the message.
+func (g *TypedGroup[T]) traceEnqueue(ctx context.Context, message *Message[T]) Span {
+	if message.trace != nil {
+		ctx = message.trace
+	}
+	var sender MemberID
+	if message.sender != nil {
+		sender = message.sender.id
+	}
+	var span Span
+	message.trace, span = g.opts.tracer.Start(ctx, SpanEnqueue, Attribute{AttrSender, int64(sender)})
+	return span
+}
+
+// traceDispatch starts the dispatch span of the message being stamped
+// with the clock and makes it the parent of the deliver spans.
+func (g *TypedGroup[T]) traceDispatch(message *Message[T], clock int64) Span {
+	var span Span
+	message.trace, span = g.opts.tracer.Start(message.traceContext(), SpanDispatch, Attribute{AttrClock, clock})
+	return span
+}
+
+func (m *TypedMember[T]) traceDeliver(message *Message[T]) (context.Context, Span) {
+	return m.group.opts.tracer.Start(message.traceContext(), SpanDeliver,
+		Attribute{AttrClock, message.clock}, Attribute{AttrMember, int64(m.id)})
+}
+
+// traceContext returns the context carrying the span of the message.
+// Messages restored from the log have no span, their deliver spans
+// start new traces.
+func (message *Message[T]) traceContext() context.Context {
+	if message.trace == nil {
+		return context.Background()
+	}
+	return message.trace
+}
+
+// MemoryTracer keeps the finished spans in memory. It is meant for
+// tests which check how the messages pass through a group.
+type MemoryTracer struct {
+	lock   sync.Mutex
+	lastID uint64
+	spans  []SpanRecord
+}
+
+// SpanRecord is a span finished by MemoryTracer.
+type SpanRecord struct {
+	ID     uint64
+	Parent uint64 // zero for the root spans
+	Name   string
+	Attrs  map[string]int64
+	Start  time.Time
+	End    time.Time
+}
+
+type memorySpan struct {
+	tracer *MemoryTracer
+	record SpanRecord
+}
+
+type memorySpanKey struct{}
+
+// NewMemoryTracer creates an empty MemoryTracer.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+// Start starts a span which is recorded when it ends.
+func (t *MemoryTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	t.lock.Lock()
+	t.lastID++
+	span := &memorySpan{
+		tracer: t,
+		record: SpanRecord{
+			ID:    t.lastID,
+			Name:  name,
+			Attrs: make(map[string]int64),
+			Start: time.Now(),
+		},
+	}
+	t.lock.Unlock()
+	if parent, ok := ctx.Value(memorySpanKey{}).(*memorySpan); ok {
+		span.record.Parent = parent.record.ID
+	}
+	for _, attr := range attrs {
+		span.record.Attrs[attr.Key] = attr.Value
+	}
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+// Spans returns the finished spans in the order they ended.
+func (t *MemoryTracer) Spans() []SpanRecord {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]SpanRecord(nil), t.spans...)
+}
+
+func (s *memorySpan) End() {
+	s.record.End = time.Now()
+	s.tracer.lock.Lock()
+	s.tracer.spans = append(s.tracer.spans, s.record)
+	s.tracer.lock.Unlock()
+}
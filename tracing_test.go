@@ -0,0 +1,102 @@
+package bcast
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Create new broadcast group with a tracer.
+// Send a message within a parent span to two members.
+// Check that the spans form the trace of the message.
+func TestTracing(t *testing.T) {
+	tracer := NewMemoryTracer()
+	group := NewGroupOf[int](WithTracer(tracer))
+	plain := group.Join()
+	enveloped := group.JoinWithOptions(WithEnvelopes())
+	go group.Broadcast(0)
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	group.SendContext(ctx, 1)
+	plain.Recv()
+	envelope := <-enveloped.Envelopes()
+	// The span of the reader is a child of the deliver span.
+	_, child := tracer.Start(envelope.Context, "reader")
+	child.End()
+	root.End()
+	group.Close()
+
+	// Deliver spans end after the value is passed to the reader, the
+	// dispatch span ends after the message is published.
+	var spans map[string][]SpanRecord
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		spans = make(map[string][]SpanRecord)
+		for _, span := range tracer.Spans() {
+			spans[span.Name] = append(spans[span.Name], span)
+		}
+		if len(spans[SpanDeliver]) == 2 && len(spans[SpanDispatch]) == 1 {
+			break
+		}
+	}
+	if len(spans[SpanEnqueue]) != 1 || len(spans[SpanDispatch]) != 1 || len(spans[SpanDeliver]) != 2 {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+	enqueue, dispatch := spans[SpanEnqueue][0], spans[SpanDispatch][0]
+	if enqueue.Parent != spans["root"][0].ID || dispatch.Parent != enqueue.ID {
+		t.Fatalf("unexpected parents %v", spans)
+	}
+	if dispatch.Attrs[AttrClock] != 0 {
+		t.Fatalf("unexpected dispatch attributes %v", dispatch.Attrs)
+	}
+	members := make(map[int64]bool)
+	for _, deliver := range spans[SpanDeliver] {
+		if deliver.Parent != dispatch.ID {
+			t.Fatalf("unexpected deliver parent %v", deliver)
+		}
+		members[deliver.Attrs[AttrMember]] = true
+		if deliver.Attrs[AttrMember] == int64(enveloped.ID()) && spans["reader"][0].Parent != deliver.ID {
+			t.Fatalf("unexpected reader parent %v", spans["reader"][0])
+		}
+	}
+	if !members[int64(plain.ID())] || !members[int64(enveloped.ID())] {
+		t.Fatalf("unexpected deliver spans %v", spans[SpanDeliver])
+	}
+}
+
+// Create new broadcast group with a tracer which retains the last
+// message.
+// Join members while the messages are sent.
+// Check that the replayed messages are delivered within their traces.
+func TestTracingRetained(t *testing.T) {
+	tracer := NewMemoryTracer()
+	group := NewGroupOf[int](WithTracer(tracer), WithRetainLast(1))
+	go group.Broadcast(0)
+	for i := 0; i < 10; i++ {
+		go group.Send(i)
+		group.Join().Recv()
+	}
+	group.Close()
+
+	// Dispatch spans end after the messages are published.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		spans := tracer.Spans()
+		dispatched := make(map[uint64]bool)
+		for _, span := range spans {
+			if span.Name == SpanDispatch {
+				dispatched[span.ID] = true
+			}
+		}
+		orphan := -1
+		for i, span := range spans {
+			if span.Name == SpanDeliver && !dispatched[span.Parent] {
+				orphan = i
+			}
+		}
+		if orphan < 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected deliver parent %v", spans[orphan])
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package bcast
+
+import (
+	"time"
+)
+
+// WithTTL makes the message expire after the time to live passes
+// since it was sent. Expired messages are skipped by the members which
+// have not taken them for delivery yet and counted in their stats.
+func WithTTL(ttl time.Duration) SendOption {
+	return func(o *sendOptions) {
+		o.deadline = time.Now().Add(ttl)
+	}
+}
+
+// WithDeadline makes the message expire at the deadline, the same way
+// as WithTTL does.
+func WithDeadline(deadline time.Time) SendOption {
+	return func(o *sendOptions) {
+		o.deadline = deadline
+	}
+}
+
+// expire reports whether the message has expired and counts it.
+func (m *TypedMember[T]) expire(message *Message[T]) bool {
+	if message.deadline.IsZero() || time.Now().Before(message.deadline) {
+		return false
+	}
+	m.expiredCount.Add(1)
+	m.group.expired.Add(1)
+	return true
+}
@@ -0,0 +1,30 @@
+package bcast
+
+import (
+	"testing"
+	"time"
+)
+
+// Create new broadcast group.
+// Send messages with TTL while the member does not read.
+// Check that the expired messages are skipped and counted.
+func TestTTL(t *testing.T) {
+	group := NewGroupOf[int]()
+	member := group.Join()
+	go group.Broadcast(0)
+	sendHeld(t, group, func() {
+		group.Send(1, WithTTL(10*time.Millisecond))
+		group.Send(2)
+		group.Send(3, WithDeadline(time.Now().Add(time.Hour)))
+		group.SendPriority(4, 1, WithTTL(10*time.Millisecond))
+	})
+	time.Sleep(20 * time.Millisecond)
+	expectOrder(t, member, -1, 2, 3)
+	group.Send(5)
+	expectOrder(t, member, 5)
+	stats := group.Stats()
+	if stats.Expired != 2 || stats.Members[0].Expired != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	group.Close()
+}